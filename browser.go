@@ -9,6 +9,7 @@ package rod
 
 import (
 	"context"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
@@ -53,6 +54,7 @@ type Browser struct {
 
 	controlURL  string
 	client      CDPClient
+	middlewares []Middleware
 	event       *goob.Observable // all the browser events from cdp client
 	targetsLock *sync.Mutex
 
@@ -238,7 +240,7 @@ func (b *Browser) Pages() (Pages, error) {
 
 // Call implements the [proto.Client] to call raw cdp interface directly.
 func (b *Browser) Call(ctx context.Context, sessionID, methodName string, params interface{}) (res []byte, err error) {
-	res, err = b.client.Call(ctx, sessionID, methodName, params)
+	res, err = b.callChain()(ctx, sessionID, methodName, params)
 	if err != nil {
 		return nil, err
 	}
@@ -443,6 +445,10 @@ func (b *Browser) initEvents() {
 	go func() {
 		defer cancel()
 		for e := range event {
+			if !b.filterEvent(e) {
+				continue
+			}
+
 			b.event.Publish(&Message{
 				SessionID: proto.TargetSessionID(e.SessionID),
 				Method:    e.Method,
@@ -501,14 +507,45 @@ func (b *Browser) SetCookies(cookies []*proto.NetworkCookieParam) error {
 // The file path will be:
 //
 //	filepath.Join(dir, info.GUID)
+//
+// If the client of the browser implements the [RemoteFiles], the file will be downloaded to the
+// remote host first, then pulled back to the dir. The errors of them are only logged, the helper returns nil
+// if the download can't be set up, use [Browser.WaitDownloadE] to get the errors.
 func (b *Browser) WaitDownload(dir string) func() (info *proto.PageDownloadWillBegin) {
+	wait, err := b.WaitDownloadE(dir)
+	if err != nil {
+		b.logger.Println("failed to wait for the download:", err)
+		return func() *proto.PageDownloadWillBegin { return nil }
+	}
+
+	return func() *proto.PageDownloadWillBegin {
+		info, err := wait()
+		if err != nil {
+			b.logger.Println("failed to pull the downloaded file:", err)
+		}
+		return info
+	}
+}
+
+// WaitDownloadE is similar to [Browser.WaitDownload], but it returns the errors of the [RemoteFiles],
+// such as it fails to get the remote dir or to pull the downloaded file back.
+func (b *Browser) WaitDownloadE(dir string) (wait func() (*proto.PageDownloadWillBegin, error), err error) {
 	var oldDownloadBehavior proto.BrowserSetDownloadBehavior
 	has := b.LoadState("", &oldDownloadBehavior)
 
+	downloadPath := dir
+	rf, remote := b.client.(RemoteFiles)
+	if remote {
+		downloadPath, err = rf.RemoteDir(b.ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	_ = proto.BrowserSetDownloadBehavior{
 		Behavior:         proto.BrowserSetDownloadBehaviorBehaviorAllowAndName,
 		BrowserContextID: b.BrowserContextID,
-		DownloadPath:     dir,
+		DownloadPath:     downloadPath,
 	}.Call(b)
 
 	var start *proto.PageDownloadWillBegin
@@ -519,7 +556,7 @@ func (b *Browser) WaitDownload(dir string) func() (info *proto.PageDownloadWillB
 		return start != nil && start.GUID == e.GUID && e.State == proto.PageDownloadProgressStateCompleted
 	})
 
-	return func() *proto.PageDownloadWillBegin {
+	return func() (*proto.PageDownloadWillBegin, error) {
 		defer func() {
 			if has {
 				_ = oldDownloadBehavior.Call(b)
@@ -533,8 +570,15 @@ func (b *Browser) WaitDownload(dir string) func() (info *proto.PageDownloadWillB
 
 		waitProgress()
 
-		return start
-	}
+		if remote && start != nil {
+			err := downloadFile(b.ctx, rf, start.GUID, filepath.Join(dir, start.GUID))
+			if err != nil {
+				return start, err
+			}
+		}
+
+		return start, nil
+	}, nil
 }
 
 // Version info of the browser.
@@ -1,8 +1,10 @@
 package rod_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/halicoming/rod"
 	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/fake"
 	"github.com/halicoming/rod/lib/devices"
 	"github.com/halicoming/rod/lib/launcher"
 	"github.com/halicoming/rod/lib/proto"
@@ -366,6 +369,13 @@ func TestWaitDownloadDataURI(t *testing.T) {
 	g.Eq("test blob", string(data))
 }
 
+func TestBrowserTetherUnsupported(t *testing.T) {
+	g := setup(t)
+
+	_, err := g.browser.Tether(3000, "127.0.0.1:3000")
+	g.Is(err, &rod.TetherUnsupportedError{})
+}
+
 func TestWaitDownloadCancel(t *testing.T) {
 	g := setup(t)
 
@@ -373,6 +383,34 @@ func TestWaitDownloadCancel(t *testing.T) {
 	g.Eq(wait(), (*proto.PageDownloadWillBegin)(nil))
 }
 
+func TestWaitDownloadRemoteDirErr(t *testing.T) {
+	g := got.T(t)
+
+	b := rod.New().Client(&brokenRemoteFiles{fake.New()}).MustConnect()
+
+	_, err := b.WaitDownloadE(os.TempDir())
+	g.Eq(err.Error(), "no remote dir")
+	g.Nil(b.WaitDownload(os.TempDir())())
+	g.Panic(func() { b.MustWaitDownload() })
+}
+
+// brokenRemoteFiles is a client that fails to transfer files.
+type brokenRemoteFiles struct {
+	*fake.Browser
+}
+
+func (brokenRemoteFiles) UploadFile(context.Context, string, io.Reader) (string, error) {
+	return "", errors.New("no upload")
+}
+
+func (brokenRemoteFiles) DownloadFile(context.Context, string, io.Writer) error {
+	return errors.New("no download")
+}
+
+func (brokenRemoteFiles) RemoteDir(context.Context) (string, error) {
+	return "", errors.New("no remote dir")
+}
+
 func TestWaitDownloadFromNewPage(t *testing.T) {
 	g := setup(t)
 
@@ -15,6 +15,7 @@ import (
 
 	"github.com/halicoming/rod/lib/assets"
 	"github.com/halicoming/rod/lib/js"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/proto"
 	"github.com/halicoming/rod/lib/utils"
 )
@@ -45,7 +46,9 @@ const (
 )
 
 // ServeMonitor starts the monitor server.
+// The metrics are served at "/metrics" in Prometheus text format, and at "/debug/vars" as json in the shape of expvar.
 // The reason why not to use "chrome://inspect/#devices" is one target cannot be driven by multiple controllers.
+// To let a DevTools frontend and rod share the same browser connection, check the lib/cdp/mux .
 func (b *Browser) ServeMonitor(host string) string {
 	u, mux, closeSvr := serve(host)
 	go func() {
@@ -80,6 +83,11 @@ func (b *Browser) ServeMonitor(host string) string {
 		w.WriteHeader(http.StatusOK)
 		utils.E(w.Write(utils.MustToJSONBytes(info)))
 	})
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		utils.E(w.Write(utils.MustToJSONBytes(map[string]interface{}{"rod": metrics.Default.Snapshot()})))
+	})
 	mux.HandleFunc("/screenshot/", func(w http.ResponseWriter, r *http.Request) {
 		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
 		target := proto.TargetTargetID(id)
@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/halicoming/rod/lib/cdp"
 	"github.com/halicoming/rod/lib/input"
 	"github.com/halicoming/rod/lib/js"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/proto"
 	"github.com/halicoming/rod/lib/utils"
 	"github.com/ysmood/gson"
@@ -34,6 +39,9 @@ type Element struct {
 	sleeper func() utils.Sleeper
 
 	page *Page
+
+	// shared by the copies of the element, so that the ElementsAlive metric is decreased only once
+	released *atomic.Bool
 }
 
 // GetSessionID interface.
@@ -404,13 +412,19 @@ func (el *Element) Disabled() (bool, error) {
 }
 
 // SetFiles of the current file input element.
+// If the client of the browser implements the [RemoteFiles], the files will be uploaded to the remote host first.
 func (el *Element) SetFiles(paths []string) error {
 	absPaths := utils.AbsolutePaths(paths)
 
 	defer el.tryTrace(TraceTypeInput, fmt.Sprintf("set files: %v", absPaths))()
 	el.page.browser.trySlowMotion()
 
-	err := proto.DOMSetFileInputFiles{
+	absPaths, err := uploadFiles(el.ctx, el.page.browser.client, absPaths)
+	if err != nil {
+		return err
+	}
+
+	err = proto.DOMSetFileInputFiles{
 		Files:    absPaths,
 		ObjectID: el.id(),
 	}.Call(el)
@@ -418,6 +432,53 @@ func (el *Element) SetFiles(paths []string) error {
 	return err
 }
 
+// File is an in-memory file for the file inputs, check [Element.SetFilesFromReaders].
+type File struct {
+	// Name of the file, such as "a.txt".
+	Name string
+
+	// MIME type of the file, such as "text/plain".
+	// If it's empty, it will be guessed from the extension of the Name.
+	MIME string
+
+	// Data of the file.
+	Data io.Reader
+}
+
+// SetFilesFromReaders of the current file input element without writing them to the disk.
+// The files are created in the page, so it works for both the local and the remote browsers,
+// but the data will be transferred via the devtools protocol, use [Element.SetFiles] for large files.
+func (el *Element) SetFilesFromReaders(files []File) error {
+	type file struct {
+		Name string `json:"name"`
+		MIME string `json:"mime"`
+		Data []byte `json:"data"`
+	}
+
+	list := []file{}
+	names := []string{}
+	for _, f := range files {
+		data, err := io.ReadAll(f.Data)
+		if err != nil {
+			return err
+		}
+
+		t := f.MIME
+		if t == "" {
+			t = mime.TypeByExtension(filepath.Ext(f.Name))
+		}
+
+		list = append(list, file{f.Name, t, data})
+		names = append(names, f.Name)
+	}
+
+	defer el.tryTrace(TraceTypeInput, fmt.Sprintf("set files: %v", names))()
+	el.page.browser.trySlowMotion()
+
+	_, err := el.Evaluate(evalHelper(js.InputFiles, list))
+	return err
+}
+
 // Describe the current element. The depth is the maximum depth at which children should be retrieved, defaults to 1,
 // use -1 for the entire subtree or provide an integer larger than 0.
 // The pierce decides whether or not iframes and shadow roots should be traversed when returning the subtree.
@@ -707,7 +768,11 @@ func (el *Element) Screenshot(format proto.PageCaptureScreenshotFormat, quality
 
 // Release is a shortcut for [Page.Release] current element.
 func (el *Element) Release() error {
-	return el.page.Context(el.ctx).Release(el.Object)
+	err := el.page.Context(el.ctx).Release(el.Object)
+	if err == nil && el.released != nil && el.released.CompareAndSwap(false, true) {
+		metrics.ElementsAlive.Dec("")
+	}
+	return err
 }
 
 // Remove the element from the page.
@@ -10,7 +10,9 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/halicoming/rod"
@@ -608,6 +610,24 @@ func TestSetFiles(t *testing.T) {
 	g.Eq("alert.html", list[1].String())
 }
 
+func TestSetFilesFromReaders(t *testing.T) {
+	g := setup(t)
+
+	p := g.page.MustNavigate(g.srcFile("fixtures/input.html"))
+	el := p.MustElement(`[type=file]`)
+	el.MustSetFilesFromReaders(
+		rod.File{Name: "a.json", Data: strings.NewReader("ok")},
+		rod.File{Name: "b.bin", MIME: "image/png", Data: bytes.NewReader([]byte{0, 255})},
+	)
+
+	list := el.MustEval(`async () => Promise.all(Array.from(this.files).map(async f =>
+		[f.name, f.type, Array.from(new Uint8Array(await f.arrayBuffer())).join(',')]))`).Arr()
+	g.Eq(list[0].Join(" "), "a.json application/json 111,107")
+	g.Eq(list[1].Join(" "), "b.bin image/png 0,255")
+
+	g.Err(el.SetFilesFromReaders([]rod.File{{Name: "a", Data: iotest.ErrReader(errors.New("err"))}}))
+}
+
 func TestEnter(t *testing.T) {
 	g := setup(t)
 
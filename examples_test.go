@@ -688,3 +688,46 @@ func Example_log_cdp_traffic() {
 
 	rod.New().Client(cdp).MustConnect().MustPage("http://mdn.dev")
 }
+
+// Use middlewares to add cross-cutting behaviors to all the cdp calls, such as per-method timeouts and retries.
+func Example_middleware() {
+	timeout := rod.Middleware{
+		Call: func(next rod.CallFunc) rod.CallFunc {
+			return func(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+				if method == "Page.captureScreenshot" {
+					var cancel func()
+					ctx, cancel = context.WithTimeout(ctx, 10*time.Second)
+					defer cancel()
+				}
+				return next(ctx, sessionID, method, params)
+			}
+		},
+	}
+
+	retry := rod.Middleware{
+		Call: func(next rod.CallFunc) rod.CallFunc {
+			return func(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+				for {
+					res, err := next(ctx, sessionID, method, params)
+					if errors.Is(err, cdp.ErrNotAttachedToActivePage) && ctx.Err() == nil {
+						utils.Sleep(0.1)
+						continue
+					}
+					return res, err
+				}
+			}
+		},
+	}
+
+	audit := rod.Middleware{
+		Event: func(e *cdp.Event) bool {
+			fmt.Println(e.Method)
+			return true
+		},
+	}
+
+	browser := rod.New().Use(timeout, retry, audit).MustConnect()
+	defer browser.MustClose()
+
+	browser.MustPage("https://github.com").MustScreenshot("")
+}
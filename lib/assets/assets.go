@@ -187,3 +187,135 @@ const MonitorPage = `<html>
   </script>
 </html>
 `
+
+// ManagerStatus page for the rod-manager.
+const ManagerStatus = `<html>
+  <head>
+    <title>Rod Manager - Sessions</title>
+    <style>
+      body {
+        margin: 0;
+        background: #2d2c2f;
+        color: white;
+        padding: 20px;
+        font-family: sans-serif;
+      }
+      a,
+      button {
+        color: white;
+        background: #212225;
+        border: 1px solid #4f475a;
+        border-radius: 0.3em;
+        padding: 0.3em 0.6em;
+        margin-right: 0.3em;
+        font-size: 0.9em;
+        text-decoration: none;
+        cursor: pointer;
+      }
+      a:hover,
+      button:hover {
+        border-color: #8d8d96;
+      }
+      table {
+        width: 100%;
+        border-collapse: collapse;
+      }
+      th,
+      td {
+        text-align: left;
+        padding: 0.5em;
+        border-bottom: 1px solid #1413158c;
+      }
+      td.flags {
+        font-family: monospace;
+        font-size: 0.8em;
+        color: #c3c3c3;
+      }
+      .error {
+        color: #ff3f3f;
+      }
+    </style>
+  </head>
+  <body>
+    <h3>Sessions</h3>
+    <p class="pool"></p>
+    <pre class="error"></pre>
+    <table>
+      <thead>
+        <tr>
+          <th>Client</th>
+          <th>PID</th>
+          <th>Started</th>
+          <th>Proxied</th>
+          <th>Flags</th>
+          <th></th>
+        </tr>
+      </thead>
+      <tbody class="sessions"></tbody>
+    </table>
+
+    <script>
+      // keep the query, such as the access_token, for the api calls
+      const api = (path) => ` + "`" + `/admin${path}${location.search}` + "`" + `
+
+      function size(n) {
+        if (n < 1024) return ` + "`" + `${n} B` + "`" + `
+        if (n < 1024 * 1024) return ` + "`" + `${(n / 1024).toFixed(1)} KB` + "`" + `
+        return ` + "`" + `${(n / 1024 / 1024).toFixed(1)} MB` + "`" + `
+      }
+
+      function escape(s) {
+        const el = document.createElement('span')
+        el.textContent = s
+        return el.innerHTML
+      }
+
+      async function kill(id) {
+        await fetch(api(` + "`" + `/sessions/${id}/kill` + "`" + `), { method: 'POST' })
+      }
+
+      async function update() {
+        try {
+          const list = await (await fetch(api('/sessions'))).json()
+          const pool = await (await fetch(api('/pool'))).json()
+
+          const total = pool.hits + pool.misses
+          document.querySelector('.pool').textContent =
+            ` + "`" + `Pool: ${pool.idle}/${pool.size} idle, ` + "`" + ` +
+            ` + "`" + `hit rate ${total ? Math.round((pool.hits / total) * 100) : 0}% (${pool.hits}/${total})` + "`" + `
+
+          let html = ''
+          list.forEach((s) => {
+            const flags = Object.entries(s.flags || {})
+              .filter(([k]) => k && !k.startsWith('rod-'))
+              .map(([k, v]) => (v ? ` + "`" + `--${k}=${v.join(',')}` + "`" + ` : ` + "`" + `--${k}` + "`" + `))
+              .join(' ')
+
+            html += ` + "`" + `<tr>
+              <td title="${escape(s.remoteAddr)}">${escape(s.client)}</td>
+              <td>${s.pid}${s.pooled ? ' (pooled)' : ''}</td>
+              <td>${new Date(s.start).toLocaleString()}</td>
+              <td>${size(s.sent)} / ${size(s.received)}</td>
+              <td class="flags">${escape(flags)}</td>
+              <td>
+                <a href="${api(` + "`" + `/sessions/${s.id}/screenshot` + "`" + `)}" target="_blank">screenshot</a>
+                <a href="${api(` + "`" + `/sessions/${s.id}/log` + "`" + `)}" target="_blank">log</a>
+                <button onclick="kill('${s.id}')">kill</button>
+              </td>
+            </tr>` + "`" + `
+          })
+
+          document.querySelector('.sessions').innerHTML = html
+          document.querySelector('.error').textContent = ''
+        } catch (err) {
+          document.querySelector('.error').textContent = err
+        }
+
+        setTimeout(update, 1000)
+      }
+
+      update()
+    </script>
+  </body>
+</html>
+`
@@ -13,18 +13,22 @@ func main() {
 	build := utils.S(`// Package assets is generated by "lib/assets/generate"
 package assets
 
-// MousePointer for rod
+// MousePointer for rod.
 const MousePointer = {{.mousePointer}}
 
-// Monitor for rod
+// Monitor for rod.
 const Monitor = {{.monitor}}
 
-// MonitorPage for rod
+// MonitorPage for rod.
 const MonitorPage = {{.monitorPage}}
+
+// ManagerStatus page for the rod-manager.
+const ManagerStatus = {{.managerStatus}}
 `,
 		"mousePointer", get("../../fixtures/mouse-pointer.svg"),
 		"monitor", get("monitor.html"),
 		"monitorPage", get("monitor-page.html"),
+		"managerStatus", get("manager.html"),
 	)
 
 	utils.E(utils.OutputFile(slash("lib/assets/assets.go"), build))
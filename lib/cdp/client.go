@@ -4,10 +4,15 @@ package cdp
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/halicoming/rod/lib/defaults"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/utils"
 )
 
@@ -84,7 +89,9 @@ type result struct {
 }
 
 // Call a method and wait for its response.
-func (cdp *Client) Call(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+func (cdp *Client) Call(ctx context.Context, sessionID, method string, params interface{}) (res []byte, err error) {
+	defer observeCall(method, time.Now(), &err)
+
 	req := &Request{
 		ID:        int(atomic.AddUint64(&cdp.count, 1)),
 		SessionID: sessionID,
@@ -122,6 +129,21 @@ func (cdp *Client) Call(ctx context.Context, sessionID, method string, params in
 	}
 }
 
+func observeCall(method string, start time.Time, err *error) {
+	metrics.CDPCallDuration.Since(method, start)
+
+	if *err == nil {
+		return
+	}
+
+	var cdpErr *Error
+	if errors.As(*err, &cdpErr) {
+		metrics.CDPCallErrors.Inc(strconv.Itoa(cdpErr.Code))
+	} else {
+		metrics.CDPCallErrors.Inc("other")
+	}
+}
+
 // Event returns a channel that will emit browser devtools protocol events. Must be consumed or will block producer.
 func (cdp *Client) Event() <-chan *Event {
 	return cdp.event
@@ -152,6 +174,7 @@ func (cdp *Client) consumeMessages() {
 			err := json.Unmarshal(data, &evt)
 			utils.E(err)
 			cdp.logger.Println(&evt)
+			metrics.CDPEvents.Inc(strings.SplitN(evt.Method, ".", 2)[0])
 			cdp.event <- &evt
 			continue
 		}
@@ -0,0 +1,307 @@
+// Package fake is a programmable in-memory browser that speaks the devtools protocol.
+// It implements the basics of the Target, Page, Runtime and DOM domains so that rod can drive it,
+// it's useful to unit test the libs built on top of rod without a real browser. Such as:
+//
+//	b := fake.New()
+//	b.Handle("Page.navigate", func(c *fake.Call) (interface{}, error) {
+//		return proto.PageNavigateResult{ErrorText: "net::ERR_NAME_NOT_RESOLVED"}, nil
+//	})
+//	page := rod.New().Client(b).MustConnect().MustPage()
+//	err := page.Navigate("http://test.com") // err will be *rod.NavigationError
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/utils"
+	"github.com/ysmood/gson"
+)
+
+// Handler of a cdp method. The returned result will be encoded as the json result of the response.
+// If the result is nil, an empty json object will be used.
+type Handler func(c *Call) (result interface{}, err error)
+
+// Call is a cdp request received by the fake browser.
+type Call struct {
+	Context   context.Context
+	Browser   *Browser
+	SessionID string
+	Method    string
+	Params    gson.JSON
+}
+
+// Default calls the built-in handler of the method, it's useful when you only want to decorate the default behavior.
+func (c *Call) Default() (interface{}, error) {
+	h, has := defaultHandlers[c.Method]
+	if !has {
+		return nil, nil
+	}
+	return h(c)
+}
+
+// Target of the fake browser.
+type Target struct {
+	ID               string
+	Type             string
+	URL              string
+	Title            string
+	BrowserContextID string
+}
+
+// Browser is a fake browser, it implements the rod.CDPClient interface.
+// All messages from Browser.Event must be received or they will block the browser.
+type Browser struct {
+	lock sync.Mutex
+
+	handlers map[string]Handler
+	delays   map[string]time.Duration
+	calls    []*Call
+	count    int
+
+	targets  []*Target
+	sessions map[string]*Target
+
+	queue  []*cdp.Event
+	notify chan struct{}
+	event  chan *cdp.Event
+
+	crashed chan struct{}
+	crash   sync.Once
+}
+
+// New fake browser instance.
+func New() *Browser {
+	b := &Browser{
+		handlers: map[string]Handler{},
+		delays:   map[string]time.Duration{},
+		sessions: map[string]*Target{},
+		notify:   make(chan struct{}, 1),
+		event:    make(chan *cdp.Event),
+		crashed:  make(chan struct{}),
+	}
+
+	go b.pump()
+
+	return b
+}
+
+// Handle overrides the handler of the method.
+func (b *Browser) Handle(method string, h Handler) *Browser {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.handlers[method] = h
+	return b
+}
+
+// Fail makes the method always return the err, such as [cdp.ErrObjNotFound].
+func (b *Browser) Fail(method string, err error) *Browser {
+	return b.Handle(method, func(*Call) (interface{}, error) {
+		return nil, err
+	})
+}
+
+// Delay the response of the method to simulate a slow browser.
+func (b *Browser) Delay(method string, d time.Duration) *Browser {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.delays[method] = d
+	return b
+}
+
+// Emit an event to the client. If the sessionID is empty the event is for the browser.
+func (b *Browser) Emit(sessionID, method string, params interface{}) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.emit(sessionID, method, params)
+}
+
+// Crash simulates the crash of the browser process, the connection will be closed.
+// All the pending and future calls will return [io.EOF].
+func (b *Browser) Crash() {
+	b.crash.Do(func() { close(b.crashed) })
+}
+
+// CrashTarget simulates the crash of the renderer of a target.
+func (b *Browser) CrashTarget(targetID string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sessionID, t := range b.sessions {
+		if t.ID == targetID {
+			b.emit(sessionID, "Inspector.targetCrashed", nil)
+		}
+	}
+	b.emit("", "Target.targetCrashed", map[string]interface{}{
+		"targetId":  targetID,
+		"status":    "crashed",
+		"errorCode": 11,
+	})
+}
+
+// Calls returns all the calls the browser has received, it's useful for assertions.
+func (b *Browser) Calls() []*Call {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]*Call{}, b.calls...)
+}
+
+// Targets returns all the living targets.
+func (b *Browser) Targets() []*Target {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	list := []*Target{}
+	for _, t := range b.targets {
+		cp := *t
+		list = append(list, &cp)
+	}
+	return list
+}
+
+// Event interface of rod.CDPClient.
+func (b *Browser) Event() <-chan *cdp.Event {
+	return b.event
+}
+
+// Call interface of rod.CDPClient.
+func (b *Browser) Call(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+	select {
+	case <-b.crashed:
+		return nil, io.EOF
+	default:
+	}
+
+	c := &Call{
+		Context:   ctx,
+		Browser:   b,
+		SessionID: sessionID,
+		Method:    method,
+		Params:    gson.New(utils.MustToJSONBytes(params)),
+	}
+
+	b.lock.Lock()
+	b.calls = append(b.calls, c)
+	h, has := b.handlers[method]
+	delay := b.delays[method]
+	b.lock.Unlock()
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.crashed:
+			return nil, io.EOF
+		case <-t.C:
+		}
+	}
+
+	err := c.check()
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		h = (*Call).Default
+	}
+
+	// the handler may block, such as a custom one that waits for something, the crash interrupts it
+	type result struct {
+		res interface{}
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := h(c)
+		done <- result{res, err}
+	}()
+
+	var res interface{}
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		res = r.res
+	case <-b.crashed:
+		return nil, io.EOF
+	}
+
+	if method == "Browser.close" {
+		defer b.Crash()
+	}
+
+	switch v := res.(type) {
+	case nil:
+		return []byte("{}"), nil
+	case []byte:
+		return v, nil
+	case json.RawMessage:
+		return v, nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+func (b *Browser) emit(sessionID, method string, params interface{}) {
+	data := json.RawMessage("{}")
+	if params != nil {
+		data = utils.MustToJSONBytes(params)
+	}
+
+	b.queue = append(b.queue, &cdp.Event{
+		SessionID: sessionID,
+		Method:    method,
+		Params:    data,
+	})
+
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends the queued events to the client in order, so that emitting events never blocks the handlers.
+func (b *Browser) pump() {
+	defer close(b.event)
+
+	for {
+		b.lock.Lock()
+		if len(b.queue) == 0 {
+			b.lock.Unlock()
+
+			select {
+			case <-b.crashed:
+				return
+			case <-b.notify:
+			}
+			continue
+		}
+		e := b.queue[0]
+		b.queue = b.queue[1:]
+		b.lock.Unlock()
+
+		select {
+		case <-b.crashed:
+			return
+		case b.event <- e:
+		}
+	}
+}
+
+func (b *Browser) newID() string {
+	b.count++
+	return fmt.Sprintf("%032X", b.count)
+}
@@ -0,0 +1,133 @@
+package fake_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/halicoming/rod"
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/fake"
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/proto"
+	"github.com/ysmood/got"
+)
+
+var setup = got.Setup(nil)
+
+func TestRod(t *testing.T) {
+	g := setup(t)
+
+	f := fake.New()
+	b := rod.New().Client(f).MustConnect()
+
+	p := b.MustPage("http://test.com")
+	g.Eq(p.MustInfo().URL, "http://test.com")
+	g.Len(b.MustPages(), 1)
+	g.Eq(b.MustVersion().Product, "Fake/1.0.0.0")
+
+	f.Handle("Runtime.callFunctionOn", func(c *fake.Call) (interface{}, error) {
+		if c.Params.Get("returnByValue").Bool() {
+			return proto.RuntimeCallFunctionOnResult{
+				Result: &proto.RuntimeRemoteObject{Type: proto.RuntimeRemoteObjectTypeNumber, Value: c.Params.Get("arguments.0.value")},
+			}, nil
+		}
+		return c.Default()
+	})
+	g.Eq(p.MustEval(`n => n`, 10).Int(), 10)
+
+	p.MustClose()
+	g.Len(f.Targets(), 0)
+
+	b.MustClose()
+	_, err := b.Call(g.Context(), "", "Browser.getVersion", nil)
+	g.Eq(err, io.EOF)
+}
+
+func TestErrors(t *testing.T) {
+	g := setup(t)
+
+	f := fake.New()
+	b := rod.New().Client(f).MustConnect()
+	p := b.MustPage()
+
+	f.Handle("Page.navigate", func(*fake.Call) (interface{}, error) {
+		return proto.PageNavigateResult{ErrorText: "net::ERR_NAME_NOT_RESOLVED"}, nil
+	})
+	g.Is(p.Navigate("http://test.com"), &rod.NavigationError{})
+
+	f.Fail("Runtime.callFunctionOn", cdp.ErrCtxNotFound)
+	_, err := p.Evaluate(rod.Eval(`() => 1`).This(&proto.RuntimeRemoteObject{ObjectID: "1"}))
+	g.Is(err, &rod.ObjectNotFoundError{})
+
+	_, err = b.Call(g.Context(), "not-exists", "Page.enable", nil)
+	g.Eq(err, cdp.ErrSessionNotFound)
+
+	_, err = b.Call(g.Context(), "", "Page.enable", nil)
+	g.Eq(err.Error(), "{-32601 'Page.enable' wasn't found }")
+
+	_, err = proto.TargetCloseTarget{TargetID: "not-exists"}.Call(b)
+	g.Eq(err, fake.ErrTargetNotFound)
+}
+
+func TestElementsAlive(t *testing.T) {
+	g := setup(t)
+
+	f := fake.New()
+	p := rod.New().Client(f).MustConnect().MustPage()
+
+	n := metrics.ElementsAlive.Get("")
+	el, err := p.ElementFromObject(&proto.RuntimeRemoteObject{Type: proto.RuntimeRemoteObjectTypeObject, ObjectID: "1"})
+	g.E(err)
+	g.Eq(metrics.ElementsAlive.Get(""), n+1)
+
+	// the copies of the element share the state
+	el.MustRelease()
+	el.Sleeper(rod.NotFoundSleeper).MustRelease()
+	g.Eq(metrics.ElementsAlive.Get(""), n)
+}
+
+func TestSlowAndCrash(t *testing.T) {
+	g := setup(t)
+
+	f := fake.New()
+	b := rod.New().Client(f).MustConnect()
+	p := b.MustPage()
+
+	f.Delay("Page.reload", time.Second)
+	ctx, cancel := context.WithTimeout(g.Context(), 10*time.Millisecond)
+	defer cancel()
+	g.Eq(proto.PageReload{}.Call(p.Context(ctx)), context.DeadlineExceeded)
+
+	wait := p.WaitEvent(&proto.InspectorTargetCrashed{})
+	f.CrashTarget(string(p.TargetID))
+	wait()
+
+	waitNav := p.MustWaitNavigation()
+	f.Emit(string(p.SessionID), "Page.lifecycleEvent", proto.PageLifecycleEvent{Name: proto.PageLifecycleEventNameNetworkAlmostIdle})
+	waitNav()
+
+	// a handler that blocks until the test ends
+	release := make(chan struct{})
+	defer close(release)
+	f.Handle("Page.stopLoading", func(*fake.Call) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+	blocked := make(chan error)
+	go func() { blocked <- proto.PageStopLoading{}.Call(p) }()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.Crash()
+	}()
+	g.Eq(proto.PageReload{}.Call(p), io.EOF)
+	g.Eq(<-blocked, io.EOF)
+	g.Eq(proto.PageStopLoading{}.Call(p), io.EOF)
+
+	_, ok := <-f.Event()
+	g.False(ok)
+
+	g.Gt(len(f.Calls()), 0)
+}
@@ -0,0 +1,389 @@
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/halicoming/rod/lib/cdp"
+)
+
+// ErrTargetNotFound is returned when the target id doesn't exist.
+var ErrTargetNotFound = &cdp.Error{
+	Code:    -32602,
+	Message: "No target with given id found",
+}
+
+// The domains that can only be called via a session.
+var sessionDomains = map[string]bool{
+	"Page":      true,
+	"Runtime":   true,
+	"DOM":       true,
+	"Emulation": true,
+	"Network":   true,
+	"Input":     true,
+	"Fetch":     true,
+}
+
+var defaultHandlers = map[string]Handler{
+	"Browser.getVersion": func(*Call) (interface{}, error) {
+		return map[string]string{
+			"protocolVersion": "1.3",
+			"product":         "Fake/1.0.0.0",
+			"revision":        "0",
+			"userAgent":       "Mozilla/5.0 Fake/1.0.0.0",
+			"jsVersion":       "0",
+		}, nil
+	},
+	"Browser.getBrowserCommandLine": func(*Call) (interface{}, error) {
+		return map[string][]string{"arguments": {"fake", "--headless"}}, nil
+	},
+
+	"Target.createTarget":          (*Call).createTarget,
+	"Target.attachToTarget":        (*Call).attachToTarget,
+	"Target.getTargets":            (*Call).getTargets,
+	"Target.getTargetInfo":         (*Call).getTargetInfo,
+	"Target.closeTarget":           (*Call).closeTarget,
+	"Target.createBrowserContext":  (*Call).createBrowserContext,
+	"Target.disposeBrowserContext": (*Call).disposeBrowserContext,
+
+	"Page.close":               (*Call).closeTarget,
+	"Page.navigate":            (*Call).navigate,
+	"Page.getFrameTree":        (*Call).getFrameTree,
+	"Page.createIsolatedWorld": (*Call).createIsolatedWorld,
+	"Page.addScriptToEvaluateOnNewDocument": func(c *Call) (interface{}, error) {
+		return map[string]string{"identifier": c.Browser.lockedID()}, nil
+	},
+
+	"Runtime.evaluate":       (*Call).evaluate,
+	"Runtime.callFunctionOn": (*Call).evaluate,
+
+	"DOM.describeNode": (*Call).describeNode,
+	"DOM.resolveNode": func(c *Call) (interface{}, error) {
+		return map[string]interface{}{"object": c.Browser.newObject("node")}, nil
+	},
+	"DOM.getOuterHTML": func(*Call) (interface{}, error) {
+		return map[string]string{"outerHTML": ""}, nil
+	},
+}
+
+// check if the session of the call is valid.
+func (c *Call) check() error {
+	domain := strings.Split(c.Method, ".")[0]
+
+	if c.SessionID == "" {
+		if sessionDomains[domain] {
+			return &cdp.Error{Code: -32601, Message: fmt.Sprintf("'%s' wasn't found", c.Method)}
+		}
+		return nil
+	}
+
+	_, err := c.target()
+	return err
+}
+
+// target of the session, it's nil if the call is not for a session.
+func (c *Call) target() (*Target, error) {
+	if c.SessionID == "" {
+		return nil, nil
+	}
+
+	c.Browser.lock.Lock()
+	defer c.Browser.lock.Unlock()
+
+	t, has := c.Browser.sessions[c.SessionID]
+	if !has {
+		return nil, cdp.ErrSessionNotFound
+	}
+	return t, nil
+}
+
+// get the string param of the key, returns empty string if the key doesn't exist.
+func (c *Call) str(key string) string {
+	if !c.Params.Has(key) {
+		return ""
+	}
+	return c.Params.Get(key).Str()
+}
+
+func (t *Target) info() map[string]interface{} {
+	return map[string]interface{}{
+		"targetId":         t.ID,
+		"type":             t.Type,
+		"title":            t.Title,
+		"url":              t.URL,
+		"attached":         true,
+		"canAccessOpener":  false,
+		"browserContextId": t.BrowserContextID,
+	}
+}
+
+func (c *Call) createTarget() (interface{}, error) {
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := &Target{
+		ID:               b.newID(),
+		Type:             "page",
+		URL:              c.str("url"),
+		BrowserContextID: c.str("browserContextId"),
+	}
+	if t.URL == "" {
+		t.URL = "about:blank"
+	}
+	t.Title = t.URL
+
+	b.targets = append(b.targets, t)
+	b.emit("", "Target.targetCreated", map[string]interface{}{"targetInfo": t.info()})
+
+	return map[string]string{"targetId": t.ID}, nil
+}
+
+func (c *Call) attachToTarget() (interface{}, error) {
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := b.findTarget(c.str("targetId"))
+	if t == nil {
+		return nil, ErrTargetNotFound
+	}
+
+	sessionID := b.newID()
+	b.sessions[sessionID] = t
+	b.emit("", "Target.attachedToTarget", map[string]interface{}{
+		"sessionId":          sessionID,
+		"targetInfo":         t.info(),
+		"waitingForDebugger": false,
+	})
+
+	return map[string]string{"sessionId": sessionID}, nil
+}
+
+func (c *Call) getTargets() (interface{}, error) {
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	list := []interface{}{}
+	for _, t := range b.targets {
+		list = append(list, t.info())
+	}
+	return map[string]interface{}{"targetInfos": list}, nil
+}
+
+func (c *Call) getTargetInfo() (interface{}, error) {
+	t, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if id := c.str("targetId"); id != "" {
+		t = b.findTarget(id)
+	}
+	if t == nil {
+		return nil, ErrTargetNotFound
+	}
+
+	return map[string]interface{}{"targetInfo": t.info()}, nil
+}
+
+func (c *Call) closeTarget() (interface{}, error) {
+	t, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if id := c.str("targetId"); id != "" {
+		t = b.findTarget(id)
+	}
+	if t == nil {
+		return nil, ErrTargetNotFound
+	}
+
+	b.removeTarget(t)
+
+	return map[string]bool{"success": true}, nil
+}
+
+func (c *Call) createBrowserContext() (interface{}, error) {
+	return map[string]string{"browserContextId": c.Browser.lockedID()}, nil
+}
+
+func (c *Call) disposeBrowserContext() (interface{}, error) {
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	id := c.str("browserContextId")
+	for _, t := range append([]*Target{}, b.targets...) {
+		if t.BrowserContextID == id {
+			b.removeTarget(t)
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *Call) navigate() (interface{}, error) {
+	t, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	b := c.Browser
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t.URL = c.str("url")
+	t.Title = t.URL
+	loaderID := b.newID()
+	now := float64(time.Now().UnixNano()) / 1e9
+
+	b.emit(c.SessionID, "Page.frameStartedLoading", map[string]interface{}{"frameId": t.ID})
+	b.emit(c.SessionID, "Page.frameNavigated", map[string]interface{}{
+		"frame": t.frame(loaderID),
+		"type":  "Navigation",
+	})
+	b.emit("", "Target.targetInfoChanged", map[string]interface{}{"targetInfo": t.info()})
+	b.emit(c.SessionID, "Page.domContentEventFired", map[string]interface{}{"timestamp": now})
+	b.emit(c.SessionID, "Page.loadEventFired", map[string]interface{}{"timestamp": now})
+	b.emit(c.SessionID, "Page.frameStoppedLoading", map[string]interface{}{"frameId": t.ID})
+
+	return map[string]string{"frameId": t.ID, "loaderId": loaderID}, nil
+}
+
+func (c *Call) getFrameTree() (interface{}, error) {
+	t, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"frameTree": map[string]interface{}{"frame": t.frame(t.ID)},
+	}, nil
+}
+
+func (c *Call) createIsolatedWorld() (interface{}, error) {
+	_, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Browser.lock.Lock()
+	defer c.Browser.lock.Unlock()
+
+	c.Browser.count++
+	return map[string]int{"executionContextId": c.Browser.count}, nil
+}
+
+// The evaluation always returns undefined for by value calls, or a new remote object for by reference calls.
+func (c *Call) evaluate() (interface{}, error) {
+	_, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Params.Get("returnByValue").Bool() {
+		return map[string]interface{}{"result": map[string]string{"type": "undefined"}}, nil
+	}
+
+	return map[string]interface{}{"result": c.Browser.newObject("")}, nil
+}
+
+func (c *Call) describeNode() (interface{}, error) {
+	t, err := c.target()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Browser.lock.Lock()
+	defer c.Browser.lock.Unlock()
+
+	c.Browser.count++
+	return map[string]interface{}{
+		"node": map[string]interface{}{
+			"nodeId":        0,
+			"backendNodeId": c.Browser.count,
+			"nodeType":      1,
+			"nodeName":      "DIV",
+			"localName":     "div",
+			"nodeValue":     "",
+			"frameId":       t.ID,
+		},
+	}, nil
+}
+
+func (t *Target) frame(loaderID string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                             t.ID,
+		"loaderId":                       loaderID,
+		"url":                            t.URL,
+		"domainAndRegistry":              "",
+		"securityOrigin":                 t.URL,
+		"mimeType":                       "text/html",
+		"secureContextType":              "Insecure",
+		"crossOriginIsolatedContextType": "NotIsolated",
+		"gatedAPIFeatures":               []string{},
+	}
+}
+
+func (b *Browser) newObject(subtype string) map[string]string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	obj := map[string]string{
+		"type":     "object",
+		"objectId": "fake-object-" + b.newID(),
+	}
+	if subtype != "" {
+		obj["subtype"] = subtype
+	}
+	return obj
+}
+
+func (b *Browser) lockedID() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.newID()
+}
+
+func (b *Browser) findTarget(id string) *Target {
+	for _, t := range b.targets {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (b *Browser) removeTarget(t *Target) {
+	for i, item := range b.targets {
+		if item == t {
+			b.targets = append(b.targets[:i:i], b.targets[i+1:]...)
+			break
+		}
+	}
+
+	for sessionID, item := range b.sessions {
+		if item == t {
+			delete(b.sessions, sessionID)
+			b.emit("", "Target.detachedFromTarget", map[string]interface{}{
+				"sessionId": sessionID,
+				"targetId":  t.ID,
+			})
+		}
+	}
+
+	b.emit("", "Target.targetDestroyed", map[string]interface{}{"targetId": t.ID})
+}
@@ -0,0 +1,528 @@
+// Package mux is a devtools protocol proxy that lets several clients share a single browser connection.
+// Such as a rod program drives the browser while a DevTools frontend inspects the same page,
+// and a logger records all the events of them.
+//
+// The mux rewrites the message ids so that the clients never see each other's responses.
+// Browser level events are sent to all the browser level clients, events of a session are only sent to
+// the clients that have attached to or used the session.
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/utils"
+	"github.com/ysmood/gson"
+)
+
+// Options of a client.
+type Options struct {
+	// TargetID makes the client a page level client, like the DevTools frontend opened for a page.
+	// The mux attaches a new session to the target for the client, requests without session id
+	// will be sent to the session, and the session id will be removed from its responses and events.
+	TargetID string
+
+	// AllEvents forwards every event of the browser to the client, it's useful for loggers.
+	AllEvents bool
+}
+
+// Mux holds the connection to the browser and routes the messages between the browser and the clients.
+type Mux struct {
+	// Logger for the attach and detach of the clients, default is [utils.LoggerQuiet].
+	Logger utils.Logger
+
+	upstream cdp.WebSocketable
+	sendLock sync.Mutex
+
+	lock    sync.Mutex
+	count   int
+	pending map[int]*pending
+	clients map[*client]struct{}
+
+	closed chan struct{}
+	err    error
+}
+
+type pending struct {
+	client *client
+	id     json.RawMessage
+	method string
+
+	// for the calls made by the mux itself
+	result chan *message
+}
+
+type message struct {
+	ID        json.RawMessage `json:"id,omitempty"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Params    json.RawMessage `json:"params,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     json.RawMessage `json:"error,omitempty"`
+}
+
+// New mux for the upstream connection, such as a [cdp.WebSocket] connected to the browser.
+func New(upstream cdp.WebSocketable) *Mux {
+	m := &Mux{
+		Logger:   utils.LoggerQuiet,
+		upstream: upstream,
+		pending:  map[int]*pending{},
+		clients:  map[*client]struct{}{},
+		closed:   make(chan struct{}),
+	}
+
+	go m.consume()
+
+	return m
+}
+
+// Done is closed when the connection to the browser is closed.
+func (m *Mux) Done() <-chan struct{} {
+	return m.closed
+}
+
+// Err returns the reason why the connection to the browser is closed.
+func (m *Mux) Err() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.err
+}
+
+// Close the connection to the browser and all the clients.
+func (m *Mux) Close() error {
+	m.shutdown(io.ErrClosedPipe)
+
+	if c, ok := m.upstream.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Serve the client until the client disconnects or the mux is closed.
+// It returns nil if the client closes the connection normally.
+func (m *Mux) Serve(ws cdp.WebSocketable, opts Options) error {
+	c := &client{
+		ws:       ws,
+		opts:     opts,
+		sessions: map[string]bool{},
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	m.lock.Lock()
+	if m.err != nil {
+		m.lock.Unlock()
+		return m.err
+	}
+	m.clients[c] = struct{}{}
+	m.lock.Unlock()
+
+	defer m.remove(c)
+
+	go c.pump()
+
+	if opts.TargetID != "" {
+		res, err := m.call(context.Background(), "", "Target.attachToTarget", map[string]interface{}{
+			"targetId": opts.TargetID,
+			"flatten":  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		session := gson.New([]byte(res)).Get("sessionId").Str()
+
+		m.lock.Lock()
+		c.session = session
+		c.sessions[session] = true
+		m.lock.Unlock()
+
+		defer func() {
+			_, _ = m.call(context.Background(), "", "Target.detachFromTarget", map[string]string{"sessionId": session})
+		}()
+	}
+
+	for {
+		data, err := ws.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var msg message
+		if json.Unmarshal(data, &msg) != nil || msg.ID == nil {
+			continue
+		}
+
+		err = m.forward(c, &msg)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ServeHTTP serves the endpoints that devtools clients use to discover and connect the browser:
+//
+//	/json/version         the browser info, its webSocketDebuggerUrl points to the mux
+//	/json/list            the targets, each webSocketDebuggerUrl is a page level client endpoint
+//	/devtools/browser     browser level client
+//	/devtools/page/{id}   page level client for the target id
+//
+// Add the query "events=all" to a websocket endpoint to receive all the events of the browser.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch p := r.URL.Path; {
+	case p == "/json/version":
+		m.version(w, r)
+
+	case p == "/json" || p == "/json/list":
+		m.list(w, r)
+
+	case strings.HasPrefix(p, "/devtools/"):
+		opts := Options{AllEvents: r.URL.Query().Get("events") == "all"}
+		if id, ok := strings.CutPrefix(p, "/devtools/page/"); ok {
+			opts.TargetID = id
+		}
+
+		ws, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer func() { _ = ws.Close() }()
+
+		m.Logger.Println("Attach", r.RemoteAddr, r.URL)
+		err = m.Serve(ws, opts)
+		m.Logger.Println("Detach", r.RemoteAddr, r.URL, err)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *Mux) version(w http.ResponseWriter, r *http.Request) {
+	res, err := m.call(r.Context(), "", "Browser.getVersion", nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	v := gson.New([]byte(res))
+	writeJSON(w, map[string]string{
+		"Browser":              v.Get("product").Str(),
+		"Protocol-Version":     v.Get("protocolVersion").Str(),
+		"User-Agent":           v.Get("userAgent").Str(),
+		"V8-Version":           v.Get("jsVersion").Str(),
+		"webSocketDebuggerUrl": wsURL(r, "/devtools/browser"),
+	})
+}
+
+func (m *Mux) list(w http.ResponseWriter, r *http.Request) {
+	res, err := m.call(r.Context(), "", "Target.getTargets", nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	list := []map[string]string{}
+	for _, t := range gson.New([]byte(res)).Get("targetInfos").Arr() {
+		id := t.Get("targetId").Str()
+		u := wsURL(r, "/devtools/page/"+id)
+		scheme, addr, _ := strings.Cut(u, "://")
+		list = append(list, map[string]string{
+			"id":                   id,
+			"type":                 t.Get("type").Str(),
+			"title":                t.Get("title").Str(),
+			"url":                  t.Get("url").Str(),
+			"webSocketDebuggerUrl": u,
+			"devtoolsFrontendUrl":  "devtools://devtools/bundled/inspector.html?" + scheme + "=" + addr,
+		})
+	}
+
+	writeJSON(w, list)
+}
+
+func (m *Mux) consume() {
+	for {
+		data, err := m.upstream.Read()
+		if err != nil {
+			m.shutdown(err)
+			return
+		}
+
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+
+		if msg.ID == nil {
+			m.broadcast(&msg)
+		} else {
+			m.respond(&msg)
+		}
+	}
+}
+
+func (m *Mux) forward(c *client, msg *message) error {
+	m.lock.Lock()
+	if m.err != nil {
+		m.lock.Unlock()
+		return m.err
+	}
+
+	m.count++
+	id := m.count
+	m.pending[id] = &pending{client: c, id: msg.ID, method: msg.Method}
+
+	if msg.SessionID == "" {
+		msg.SessionID = c.session
+	}
+	if msg.SessionID != "" {
+		c.sessions[msg.SessionID] = true
+	}
+	m.lock.Unlock()
+
+	msg.ID = json.RawMessage(gson.New(id).JSON("", ""))
+
+	err := m.send(msg)
+	if err != nil {
+		m.drop(id)
+	}
+	return err
+}
+
+func (m *Mux) call(ctx context.Context, sessionID, method string, params interface{}) (json.RawMessage, error) {
+	p := &pending{result: make(chan *message, 1)}
+
+	m.lock.Lock()
+	if m.err != nil {
+		m.lock.Unlock()
+		return nil, m.err
+	}
+	m.count++
+	id := m.count
+	m.pending[id] = p
+	m.lock.Unlock()
+
+	data, err := json.Marshal(cdp.Request{ID: id, SessionID: sessionID, Method: method, Params: params})
+	utils.E(err)
+
+	var msg message
+	utils.E(json.Unmarshal(data, &msg))
+
+	err = m.send(&msg)
+	if err != nil {
+		m.drop(id)
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		m.drop(id)
+		return nil, ctx.Err()
+	case <-m.closed:
+		return nil, m.Err()
+	case res := <-p.result:
+		if res.Error != nil {
+			var e cdp.Error
+			utils.E(json.Unmarshal(res.Error, &e))
+			return nil, &e
+		}
+		return res.Result, nil
+	}
+}
+
+func (m *Mux) send(msg *message) error {
+	data, err := json.Marshal(msg)
+	utils.E(err)
+
+	m.sendLock.Lock()
+	defer m.sendLock.Unlock()
+
+	return m.upstream.Send(data)
+}
+
+func (m *Mux) respond(msg *message) {
+	var id int
+	if json.Unmarshal(msg.ID, &id) != nil {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	p, has := m.pending[id]
+	if !has {
+		return
+	}
+	delete(m.pending, id)
+
+	if p.result != nil {
+		p.result <- msg
+		return
+	}
+
+	if _, alive := m.clients[p.client]; !alive {
+		return
+	}
+
+	if p.method == "Target.attachToTarget" && msg.Result != nil {
+		if s := gson.New([]byte(msg.Result)).Get("sessionId").Str(); s != "" {
+			p.client.sessions[s] = true
+		}
+	}
+
+	msg.ID = p.id
+	p.client.send(msg)
+}
+
+func (m *Mux) broadcast(msg *message) {
+	// the session that the event is about, such as the one in Target.attachedToTarget
+	child := ""
+	switch msg.Method {
+	case "Target.attachedToTarget", "Target.detachedFromTarget":
+		child = gson.New([]byte(msg.Params)).Get("sessionId").Str()
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for c := range m.clients {
+		if !c.wants(msg) {
+			continue
+		}
+
+		switch {
+		case msg.Method == "Target.detachedFromTarget":
+			delete(c.sessions, child)
+		case msg.Method == "Target.attachedToTarget" && msg.SessionID != "":
+			// the target is auto attached by a session that the client owns
+			c.sessions[child] = true
+		}
+
+		c.send(msg)
+	}
+}
+
+func (m *Mux) drop(id int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.pending, id)
+}
+
+func (m *Mux) remove(c *client) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.clients, c)
+	c.close()
+}
+
+func (m *Mux) shutdown(err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.err != nil {
+		return
+	}
+	m.err = err
+	close(m.closed)
+
+	for c := range m.clients {
+		c.close()
+		if closer, ok := c.ws.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+type client struct {
+	ws   cdp.WebSocketable
+	opts Options
+
+	// guarded by Mux.lock
+	session  string
+	sessions map[string]bool
+
+	lock   sync.Mutex
+	queue  []*message
+	notify chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (c *client) wants(msg *message) bool {
+	if c.opts.AllEvents {
+		return true
+	}
+	if msg.SessionID == "" {
+		return c.opts.TargetID == ""
+	}
+	return c.sessions[msg.SessionID]
+}
+
+// send queues the msg, so that a slow client won't block the others.
+func (c *client) send(msg *message) {
+	cp := *msg
+	if cp.SessionID == c.session {
+		cp.SessionID = ""
+	}
+
+	c.lock.Lock()
+	c.queue = append(c.queue, &cp)
+	c.lock.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *client) pump() {
+	for {
+		c.lock.Lock()
+		if len(c.queue) == 0 {
+			c.lock.Unlock()
+
+			select {
+			case <-c.done:
+				return
+			case <-c.notify:
+			}
+			continue
+		}
+		msg := c.queue[0]
+		c.queue = c.queue[1:]
+		c.lock.Unlock()
+
+		data, err := json.Marshal(msg)
+		utils.E(err)
+
+		if c.ws.Send(data) != nil {
+			return
+		}
+	}
+}
+
+func (c *client) close() {
+	c.once.Do(func() { close(c.done) })
+}
+
+func wsURL(r *http.Request, path string) string {
+	scheme := "ws"
+	if r.TLS != nil {
+		scheme = "wss"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
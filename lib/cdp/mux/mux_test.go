@@ -0,0 +1,275 @@
+package mux_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/halicoming/rod"
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/fake"
+	"github.com/halicoming/rod/lib/cdp/mux"
+	"github.com/halicoming/rod/lib/launcher"
+	"github.com/ysmood/got"
+	"github.com/ysmood/gson"
+)
+
+var setup = got.Setup(nil)
+
+func TestMux(t *testing.T) {
+	g := setup(t)
+
+	f := fake.New()
+	m := mux.New(newUpstream(f))
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+
+	ws := strings.Replace(s.HostURL.String(), "http", "ws", 1)
+	connect := func(path string) (*cdp.Client, <-chan *cdp.Event) {
+		c := cdp.MustStartWithURL(g.Context(), ws+path, nil)
+		return c, bufferEvents(c)
+	}
+
+	a, aEvents := connect("/devtools/browser")
+	b, bEvents := connect("/devtools/browser")
+	logger, loggerEvents := connect("/devtools/browser?events=all")
+
+	// both clients start their ids from 1
+	_, err := b.Call(g.Context(), "", "Browser.getVersion", nil)
+	g.E(err)
+
+	res, err := a.Call(g.Context(), "", "Target.createTarget", map[string]string{"url": "about:blank"})
+	g.E(err)
+	targetID := gson.New(res).Get("targetId").Str()
+
+	res, err = a.Call(g.Context(), "", "Target.attachToTarget", map[string]interface{}{"targetId": targetID, "flatten": true})
+	g.E(err)
+	sessionID := gson.New(res).Get("sessionId").Str()
+
+	f.Emit(sessionID, "Page.loadEventFired", map[string]int{"timestamp": 1})
+	f.Emit("", "Test.marker", nil)
+
+	g.Eq(waitFor(aEvents, "Test.marker"), []string{
+		"Target.targetCreated", "Target.attachedToTarget", "Page.loadEventFired",
+	})
+	g.Eq(waitFor(bEvents, "Test.marker"), []string{
+		"Target.targetCreated", "Target.attachedToTarget",
+	})
+	g.Has(waitFor(loggerEvents, "Test.marker"), "Page.loadEventFired")
+
+	// page level client
+	p, pEvents := connect("/devtools/page/" + targetID)
+	res, err = p.Call(g.Context(), "", "Runtime.evaluate", map[string]interface{}{"expression": "1", "returnByValue": true})
+	g.E(err)
+	g.Eq(gson.New(res).Get("result.type").Str(), "undefined")
+
+	f.CrashTarget(targetID)
+	e := <-pEvents
+	g.Eq(e.Method, "Inspector.targetCrashed")
+	g.Eq(e.SessionID, "")
+
+	// the mux is discoverable like a browser
+	u := launcher.MustResolveURL(s.HostURL.String())
+	g.Eq(u, ws+"/devtools/browser")
+	g.Has(rod.New().ControlURL(u).MustConnect().MustVersion().Product, "Fake")
+
+	list := gson.New(g.Req("", s.URL("/json/list")).Bytes().Bytes())
+	g.Eq(list.Get("0.id").Str(), targetID)
+	g.Eq(list.Get("0.webSocketDebuggerUrl").Str(), ws+"/devtools/page/"+targetID)
+
+	g.E(logger.Call(g.Context(), "", "Browser.getVersion", nil))
+
+	g.E(m.Close())
+	<-m.Done()
+	_, err = a.Call(g.Context(), "", "Browser.getVersion", nil)
+	g.Err(err)
+}
+
+func TestUpgradeErr(t *testing.T) {
+	g := setup(t)
+
+	s := g.Serve()
+	s.Mux.Handle("/", mux.New(newUpstream(fake.New())))
+
+	res := g.Req("", s.URL("/devtools/browser"))
+	g.Eq(res.StatusCode, http.StatusBadRequest)
+
+	res = g.Req("", s.URL("/not-found"))
+	g.Eq(res.StatusCode, http.StatusNotFound)
+}
+
+func TestWebSocketLimits(t *testing.T) {
+	g := setup(t)
+
+	msgs := make(chan string, 10)
+	errs := make(chan error, 1)
+	s := g.Serve()
+	s.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := mux.Upgrade(w, r)
+		g.E(err)
+		ws.MaxMessageSize = 10
+		for {
+			msg, err := ws.Read()
+			if err != nil {
+				errs <- err
+				return
+			}
+			msgs <- string(msg)
+		}
+	})
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", s.HostURL.Host)
+		g.E(err)
+		g.Cleanup(func() { _ = conn.Close() })
+
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Key: a2V5\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+		g.E(err)
+
+		r := bufio.NewReader(conn)
+		res, err := http.ReadResponse(r, nil)
+		g.E(err)
+		g.Eq(res.StatusCode, http.StatusSwitchingProtocols)
+		return conn, r
+	}
+
+	closeCode := func(r *bufio.Reader) int {
+		b := make([]byte, 4)
+		_, err := io.ReadFull(r, b)
+		g.E(err)
+		g.Eq(b[:2], []byte{0x88, 2})
+		return int(binary.BigEndian.Uint16(b[2:]))
+	}
+
+	conn, r := dial()
+	_, err := conn.Write([]byte{0x81, 0x82, 0, 0, 0, 0, 'o', 'k'})
+	g.E(err)
+	g.Eq(<-msgs, "ok")
+	_, err = conn.Write([]byte{0x81, 0x02, 'h', 'i'})
+	g.E(err)
+	g.Is(<-errs, mux.ErrUnmaskedFrame)
+	g.Eq(closeCode(r), 1002)
+
+	// a single header that claims a huge payload
+	conn, r = dial()
+	_, err = conn.Write([]byte{0x81, 0xff, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0})
+	g.E(err)
+	g.Is(<-errs, mux.ErrMessageTooLarge)
+	g.Eq(closeCode(r), 1009)
+
+	// the continuation frames of a message are accumulated
+	conn, r = dial()
+	_, err = conn.Write([]byte{
+		0x01, 0x86, 0, 0, 0, 0, 'a', 'a', 'a', 'a', 'a', 'a',
+		0x80, 0x85, 0, 0, 0, 0, 'b', 'b', 'b', 'b', 'b',
+	})
+	g.E(err)
+	g.Is(<-errs, mux.ErrMessageTooLarge)
+	g.Eq(closeCode(r), 1009)
+}
+
+// waitFor returns the methods of the events before the method.
+func waitFor(events <-chan *cdp.Event, method string) []string {
+	list := []string{}
+	for e := range events {
+		if e.Method == method {
+			break
+		}
+		list = append(list, e.Method)
+	}
+	return list
+}
+
+func bufferEvents(c *cdp.Client) <-chan *cdp.Event {
+	events := make(chan *cdp.Event, 100)
+	go func() {
+		defer close(events)
+		for e := range c.Event() {
+			events <- e
+		}
+	}()
+	return events
+}
+
+// upstream turns the fake browser into a websocket connection.
+type upstream struct {
+	b   *fake.Browser
+	out chan []byte
+	eof chan struct{}
+}
+
+func newUpstream(b *fake.Browser) *upstream {
+	u := &upstream{b: b, out: make(chan []byte, 100), eof: make(chan struct{})}
+
+	go func() {
+		defer close(u.eof)
+		for e := range b.Event() {
+			data, _ := json.Marshal(e)
+			u.out <- data
+		}
+	}()
+
+	return u
+}
+
+func (u *upstream) Send(data []byte) error {
+	var req struct {
+		ID        int             `json:"id"`
+		SessionID string          `json:"sessionId"`
+		Method    string          `json:"method"`
+		Params    json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+
+	go func() {
+		res := cdp.Response{ID: req.ID}
+
+		var params interface{}
+		if req.Params != nil {
+			params = req.Params
+		}
+
+		result, err := u.b.Call(context.Background(), req.SessionID, req.Method, params)
+		if err != nil {
+			e := &cdp.Error{}
+			if !errors.As(err, &e) {
+				return
+			}
+			res.Error = e
+		} else {
+			res.Result = result
+		}
+
+		data, _ := json.Marshal(res)
+		select {
+		case u.out <- data:
+		case <-u.eof:
+		}
+	}()
+
+	return nil
+}
+
+func (u *upstream) Read() ([]byte, error) {
+	select {
+	case data := <-u.out:
+		return data, nil
+	case <-u.eof:
+		return nil, io.EOF
+	}
+}
+
+func (u *upstream) Close() error {
+	u.b.Crash()
+	return nil
+}
@@ -0,0 +1,234 @@
+package mux
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/halicoming/rod/lib/cdp"
+)
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+)
+
+var _ cdp.WebSocketable = &WebSocket{}
+
+// DefaultMaxMessageSize is the default [WebSocket.MaxMessageSize].
+const DefaultMaxMessageSize = 64 << 20
+
+// WebSocket is the server side of a websocket connection. It only implements the subset of
+// the WebSocket protocol that devtools clients use.
+// Ref: https://tools.ietf.org/html/rfc6455
+type WebSocket struct {
+	// MaxMessageSize is the max bytes of a message from the client, including all of its frames.
+	// The connection fails if a message exceeds it.
+	MaxMessageSize int64
+
+	lock sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// ErrNotWebSocket is returned by [Upgrade] when the request is not a websocket handshake.
+var ErrNotWebSocket = errors.New("not a websocket handshake")
+
+// ErrMessageTooLarge is returned by [WebSocket.Read] when a message exceeds the [WebSocket.MaxMessageSize].
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// ErrUnmaskedFrame is returned by [WebSocket.Read] when the client sends an unmasked frame,
+// the RFC 6455 section 5.1 requires the connection to fail.
+var ErrUnmaskedFrame = errors.New("websocket frame from the client is not masked")
+
+// Upgrade the http request to a websocket connection.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, ErrNotWebSocket.Error(), http.StatusBadRequest)
+		return nil, ErrNotWebSocket
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijack not supported", http.StatusInternalServerError)
+		return nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &WebSocket{MaxMessageSize: DefaultMaxMessageSize, conn: conn, r: rw.Reader}, nil
+}
+
+func acceptKey(key string) string {
+	hash := sha1.New()
+	hash.Write([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// Send a text message to the client.
+func (ws *WebSocket) Send(msg []byte) error {
+	err := ws.write(opText, msg)
+	if err != nil {
+		_ = ws.Close()
+	}
+	return err
+}
+
+// Read a text message from the client. It returns [io.EOF] when the client closes the connection.
+func (ws *WebSocket) Read() ([]byte, error) {
+	var msg []byte
+	for {
+		fin, op, data, err := ws.frame(int64(len(msg)))
+		if err != nil {
+			ws.fail(err)
+			return nil, err
+		}
+
+		switch op {
+		case opClose:
+			_ = ws.write(opClose, nil)
+			_ = ws.Close()
+			return nil, io.EOF
+		case opPing:
+			err = ws.write(opPong, data)
+			if err != nil {
+				_ = ws.Close()
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		}
+
+		msg = append(msg, data...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// fail the connection with the close status code of the err.
+func (ws *WebSocket) fail(err error) {
+	code := 0
+	switch {
+	case errors.Is(err, ErrMessageTooLarge):
+		code = 1009
+	case errors.Is(err, ErrUnmaskedFrame):
+		code = 1002
+	}
+	if code != 0 {
+		_ = ws.write(opClose, binary.BigEndian.AppendUint16(nil, uint16(code)))
+	}
+	_ = ws.Close()
+}
+
+// Close the underlying connection.
+func (ws *WebSocket) Close() error {
+	return ws.conn.Close()
+}
+
+func (ws *WebSocket) write(op byte, msg []byte) error {
+	header := [10]byte{0b1000_0000 | op}
+
+	size := len(msg)
+	n := 2
+	switch {
+	case size <= 125:
+		header[1] = byte(size)
+	case size < 65536:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(size))
+		n = 4
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(size))
+		n = 10
+	}
+
+	data := make([]byte, n+size)
+	copy(data, header[:n])
+	copy(data[n:], msg)
+
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	_, err := ws.conn.Write(data)
+	return err
+}
+
+// frame reads the next frame, the received is the size of the previous frames of the message.
+func (ws *WebSocket) frame(received int64) (fin bool, op byte, data []byte, err error) {
+	var header [2]byte
+	_, err = io.ReadFull(ws.r, header[:])
+	if err != nil {
+		return
+	}
+
+	fin = header[0]&0b1000_0000 != 0
+	op = header[0] & 0x0f
+	if header[1]&0b1000_0000 == 0 {
+		err = ErrUnmaskedFrame
+		return
+	}
+
+	size := uint64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var b [2]byte
+		_, err = io.ReadFull(ws.r, b[:])
+		size = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, err = io.ReadFull(ws.r, b[:])
+		size = binary.BigEndian.Uint64(b[:])
+	}
+	if err != nil {
+		return
+	}
+
+	if size > uint64(ws.MaxMessageSize-received) {
+		err = fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, ws.MaxMessageSize)
+		return
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(ws.r, mask[:])
+	if err != nil {
+		return
+	}
+
+	data = make([]byte, size)
+	_, err = io.ReadFull(ws.r, data)
+	if err != nil {
+		return
+	}
+
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+
+	return
+}
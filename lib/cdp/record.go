@@ -0,0 +1,374 @@
+package cdp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// RecordType of a RecordEntry.
+type RecordType string
+
+const (
+	// RecordTypeRequest type.
+	RecordTypeRequest RecordType = "request"
+
+	// RecordTypeResponse type.
+	RecordTypeResponse RecordType = "response"
+
+	// RecordTypeEvent type.
+	RecordTypeEvent RecordType = "event"
+)
+
+// RecordEntry is a line of the JSONL file created by the [Recorder].
+type RecordEntry struct {
+	// Time since the recording started
+	Time time.Duration `json:"time"`
+
+	Type RecordType      `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+var _ WebSocketable = &Recorder{}
+
+// Recorder wraps a WebSocketable to record every message transferred between the Client and the browser.
+// Each message is written as a JSON line of [RecordEntry] to the writer, the output can be replayed by the [Replayer].
+// Such as:
+//
+//	f, _ := os.Create("session.jsonl")
+//	client := cdp.New().Start(cdp.NewRecorder(cdp.MustConnectWS(u), f))
+type Recorder struct {
+	ws    WebSocketable
+	start time.Time
+
+	lock sync.Mutex
+	w    io.Writer
+	err  error
+}
+
+// NewRecorder instance.
+func NewRecorder(ws WebSocketable, w io.Writer) *Recorder {
+	return &Recorder{
+		ws:    ws,
+		start: time.Now(),
+		w:     w,
+	}
+}
+
+// Send interface.
+func (r *Recorder) Send(data []byte) error {
+	// The underlying websocket may modify the data, such as WebSocket.Send, so we record it first.
+	r.record(RecordTypeRequest, data)
+	return r.ws.Send(data)
+}
+
+// Read interface.
+func (r *Recorder) Read() ([]byte, error) {
+	data, err := r.ws.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var id struct {
+		ID int `json:"id"`
+	}
+	if json.Unmarshal(data, &id) == nil && id.ID != 0 {
+		r.record(RecordTypeResponse, data)
+	} else {
+		r.record(RecordTypeEvent, data)
+	}
+
+	return data, nil
+}
+
+// Err returns the first error when writing to the writer.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(typ RecordType, data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	b, err := json.Marshal(RecordEntry{
+		Time: time.Since(r.start),
+		Type: typ,
+		Data: append(json.RawMessage{}, data...),
+	})
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	_, r.err = r.w.Write(append(b, '\n'))
+}
+
+// ReplayNotFoundError is returned by the [Replayer] when the request doesn't match any recorded request.
+type ReplayNotFoundError struct {
+	Method string
+	Params json.RawMessage
+}
+
+func (e *ReplayNotFoundError) Error() string {
+	return fmt.Sprintf("cdp replay: no recorded request matches %s %s", e.Method, e.Params)
+}
+
+// Is interface.
+func (e *ReplayNotFoundError) Is(err error) bool { _, ok := err.(*ReplayNotFoundError); return ok }
+
+var _ WebSocketable = &Replayer{}
+
+// Replayer replays the recording created by the [Recorder] without a browser.
+// A request is matched with the first unused recorded request that has the same method and params,
+// its recorded response and the events received after it will be sent back to the Client in the recorded order.
+// The recorded message ids are rewritten to the ones of the live requests, so the replay is deterministic.
+type Replayer struct {
+	lock     sync.Mutex
+	requests []*replayRequest
+	queue    [][]byte
+
+	notify chan struct{}
+	closed chan struct{}
+	close  sync.Once
+}
+
+type rawRequest struct {
+	ID        int             `json:"id"`
+	SessionID string          `json:"sessionId"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+}
+
+type replayRequest struct {
+	sessionID string
+	method    string
+	params    interface{}
+	used      bool
+
+	// the recorded response and events that follow the request
+	replies []*RecordEntry
+}
+
+// NewReplayer parses the recording from r.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	rp := &Replayer{
+		notify: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+
+	// events received before any request
+	head := &replayRequest{used: true}
+	last := head
+	byID := map[int]*replayRequest{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e RecordEntry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Type {
+		case RecordTypeEvent:
+			last.replies = append(last.replies, &e)
+
+		case RecordTypeResponse:
+			// A response may arrive after other requests are sent, it always belongs to the request with the same id.
+			var res struct {
+				ID int `json:"id"`
+			}
+			err = json.Unmarshal(e.Data, &res)
+			if err != nil {
+				return nil, err
+			}
+			if req, has := byID[res.ID]; has {
+				req.replies = append(req.replies, &e)
+			}
+
+		case RecordTypeRequest:
+			var req rawRequest
+			err = json.Unmarshal(e.Data, &req)
+			if err != nil {
+				return nil, err
+			}
+
+			params, err := normalizeParams(req.Params)
+			if err != nil {
+				return nil, err
+			}
+
+			last = &replayRequest{
+				sessionID: req.SessionID,
+				method:    req.Method,
+				params:    params,
+			}
+			byID[req.ID] = last
+			rp.requests = append(rp.requests, last)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rp.enqueue(head, 0)
+
+	return rp, nil
+}
+
+// MustNewReplayer is similar to NewReplayer.
+func MustNewReplayer(r io.Reader) *Replayer {
+	rp, err := NewReplayer(r)
+	utils.E(err)
+	return rp
+}
+
+// Send interface.
+func (rp *Replayer) Send(data []byte) error {
+	select {
+	case <-rp.closed:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	var req rawRequest
+	err := json.Unmarshal(data, &req)
+	if err != nil {
+		return err
+	}
+
+	params, err := normalizeParams(req.Params)
+	if err != nil {
+		return err
+	}
+
+	rp.lock.Lock()
+	var matched *replayRequest
+	for _, r := range rp.requests {
+		if !r.used && r.method == req.Method && r.sessionID == req.SessionID && reflect.DeepEqual(r.params, params) {
+			r.used = true
+			matched = r
+			break
+		}
+	}
+	rp.lock.Unlock()
+
+	if matched == nil {
+		return &ReplayNotFoundError{Method: req.Method, Params: req.Params}
+	}
+
+	rp.enqueue(matched, req.ID)
+
+	return nil
+}
+
+// Read interface. It returns [io.EOF] after the [Replayer.Close] is called.
+func (rp *Replayer) Read() ([]byte, error) {
+	for {
+		select {
+		case <-rp.closed:
+			return nil, io.EOF
+		default:
+		}
+
+		rp.lock.Lock()
+		if len(rp.queue) > 0 {
+			msg := rp.queue[0]
+			rp.queue = rp.queue[1:]
+			rp.lock.Unlock()
+			return msg, nil
+		}
+		rp.lock.Unlock()
+
+		select {
+		case <-rp.closed:
+			return nil, io.EOF
+		case <-rp.notify:
+		}
+	}
+}
+
+// Close the replayer, it simulates the disconnection of the browser.
+func (rp *Replayer) Close() error {
+	rp.close.Do(func() { close(rp.closed) })
+	return nil
+}
+
+// Remaining returns the number of recorded requests that haven't been replayed yet.
+func (rp *Replayer) Remaining() int {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	n := 0
+	for _, r := range rp.requests {
+		if !r.used {
+			n++
+		}
+	}
+	return n
+}
+
+// enqueue the replies of the req with the message id rewritten to id.
+func (rp *Replayer) enqueue(req *replayRequest, id int) {
+	list := [][]byte{}
+	for _, e := range req.replies {
+		data := e.Data
+
+		if e.Type == RecordTypeResponse {
+			var res Response
+			if json.Unmarshal(e.Data, &res) != nil {
+				continue
+			}
+			res.ID = id
+
+			var err error
+			data, err = json.Marshal(res)
+			if err != nil {
+				continue
+			}
+		}
+
+		list = append(list, data)
+	}
+
+	rp.lock.Lock()
+	rp.queue = append(rp.queue, list...)
+	rp.lock.Unlock()
+
+	select {
+	case rp.notify <- struct{}{}:
+	default:
+	}
+}
+
+// normalizeParams makes the params comparable regardless of the key order and spacing.
+func normalizeParams(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v interface{}
+	err := json.Unmarshal(raw, &v)
+	if err != nil {
+		return nil, errors.New("cdp replay: invalid params: " + err.Error())
+	}
+	return v, nil
+}
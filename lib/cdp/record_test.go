@@ -0,0 +1,107 @@
+package cdp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/ysmood/gson"
+)
+
+func TestRecordReplay(t *testing.T) {
+	g := setup(t)
+
+	req := make(chan []byte, 10)
+
+	ws := &MockWebSocket{
+		send: func(data []byte) error {
+			req <- append([]byte{}, data...)
+			return nil
+		},
+		read: func() ([]byte, error) {
+			data, ok := <-req
+			if !ok {
+				return nil, io.EOF
+			}
+
+			var r cdp.Request
+			g.E(json.Unmarshal(data, &r))
+
+			if r.Method == "event" {
+				req <- []byte(`{"id":` + gson.New(r.ID).JSON("", "") + `,"result":{}}`)
+				return json.Marshal(cdp.Event{Method: "Page.loadEventFired", Params: json.RawMessage(`{"timestamp":1}`)})
+			}
+
+			return json.Marshal(cdp.Response{
+				ID:     r.ID,
+				Result: json.RawMessage(gson.New(map[string]interface{}{"echo": r.Params}).JSON("", "")),
+			})
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	rec := cdp.NewRecorder(ws, buf)
+	c := cdp.New().Start(rec)
+	events := bufferEvents(c)
+
+	res, err := c.Call(g.Context(), "", "echo", map[string]int{"a": 1, "b": 2})
+	g.E(err)
+	g.Eq(gson.New(res).Get("echo.b").Int(), 2)
+
+	_, err = c.Call(g.Context(), "session", "event", nil)
+	g.E(err)
+	g.Eq((<-events).Method, "Page.loadEventFired")
+
+	close(req)
+	g.E(rec.Err())
+	g.Len(strings.Split(strings.TrimSpace(buf.String()), "\n"), 5)
+
+	rp := cdp.MustNewReplayer(bytes.NewReader(buf.Bytes()))
+	g.Eq(rp.Remaining(), 2)
+
+	c = cdp.New().Start(rp)
+	events = bufferEvents(c)
+
+	_, err = c.Call(g.Context(), "", "echo", map[string]int{"a": 1})
+	g.Is(err, &cdp.ReplayNotFoundError{})
+	g.Has(err.Error(), `no recorded request matches echo {"a":1}`)
+
+	// the key order doesn't matter
+	res, err = c.Call(g.Context(), "", "echo", json.RawMessage(`{"b":2,"a":1}`))
+	g.E(err)
+	g.Eq(gson.New(res).Get("echo.a").Int(), 1)
+
+	_, err = c.Call(g.Context(), "session", "event", nil)
+	g.E(err)
+	e := <-events
+	g.Eq(e.Method, "Page.loadEventFired")
+	g.Eq(gson.New([]byte(e.Params)).Get("timestamp").Int(), 1)
+
+	g.Eq(rp.Remaining(), 0)
+
+	// each recorded request can only be replayed once
+	_, err = c.Call(g.Context(), "", "echo", map[string]int{"a": 1, "b": 2})
+	g.Err(err)
+
+	g.E(rp.Close())
+	_, ok := <-events
+	g.False(ok)
+	g.Eq(rp.Send(nil), io.ErrClosedPipe)
+
+	g.Err(cdp.NewReplayer(strings.NewReader("{")))
+	g.Err(cdp.NewReplayer(strings.NewReader(`{"type":"request","data":1}`)))
+}
+
+func bufferEvents(c *cdp.Client) <-chan *cdp.Event {
+	events := make(chan *cdp.Event, 10)
+	go func() {
+		defer close(events)
+		for e := range c.Event() {
+			events <- e
+		}
+	}()
+	return events
+}
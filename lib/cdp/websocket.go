@@ -23,6 +23,9 @@ type WebSocket struct {
 	// Dialer is usually used for proxy
 	Dialer Dialer
 
+	// ResponseHeader of the handshake, it's set after the Connect succeeds
+	ResponseHeader http.Header
+
 	lock sync.Mutex
 	conn net.Conn
 	r    *bufio.Reader
@@ -234,5 +237,7 @@ func (ws *WebSocket) handshake(ctx context.Context, u *url.URL, header http.Head
 		}
 	}
 
+	ws.ResponseHeader = res.Header
+
 	return nil
 }
@@ -47,6 +47,10 @@ var Port string
 // Option name is "bin".
 var Bin string
 
+// Version is the default of launcher.Browser.Version , such as "stable" or "120".
+// Option name is "version".
+var Version string
+
 // Proxy is the default of launcher.Launcher.Proxy
 // Option name is "proxy".
 var Proxy string
@@ -55,6 +59,10 @@ var Proxy string
 // Option name is "lock".
 var LockPort int
 
+// Profile is the name of the launcher profile to apply, check launcher.Load for details.
+// Option name is "profile", such as "profile=ci" or the shortcut "profile:ci".
+var Profile string
+
 // URL is the default websocket url for remote control a browser.
 // Option name is "url".
 var URL string
@@ -73,8 +81,10 @@ func Reset() {
 	Dir = ""
 	Port = "0"
 	Bin = ""
+	Version = ""
 	Proxy = ""
 	LockPort = 2978
+	Profile = ""
 	URL = ""
 	CDP = utils.LoggerQuiet
 }
@@ -113,6 +123,9 @@ var envParsers = map[string]func(string){
 	"bin": func(v string) {
 		Bin = v
 	},
+	"version": func(v string) {
+		Version = v
+	},
 	"proxy": func(v string) {
 		Proxy = v
 	},
@@ -122,6 +135,9 @@ var envParsers = map[string]func(string){
 			LockPort = int(i)
 		}
 	},
+	"profile": func(v string) {
+		Profile = v
+	},
 	"url": func(v string) {
 		URL = v
 	},
@@ -194,6 +210,11 @@ func parse(options string) {
 			continue
 		}
 
+		// the shortcut of "profile=name"
+		if name, ok := strings.CutPrefix(n, "profile:"); ok && len(kv) == 1 {
+			n, v = "profile", name
+		}
+
 		f := envParsers[n]
 		if f == nil {
 			panic("unknown rod env option: " + n)
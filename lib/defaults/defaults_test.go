@@ -24,8 +24,8 @@ func TestBasic(t *testing.T) {
 	g.Eq(2978, LockPort)
 
 	parse("show,devtools,trace,slow=2s,port=8080,dir=tmp," +
-		"url=http://test.com,cdp,monitor,bin=/path/to/chrome," +
-		"proxy=localhost:8080,lock=9981,",
+		"url=http://test.com,cdp,monitor,bin=/path/to/chrome,version=stable," +
+		"proxy=localhost:8080,lock=9981,profile=ci,",
 	)
 
 	g.True(Show)
@@ -34,12 +34,17 @@ func TestBasic(t *testing.T) {
 	g.Eq(2*time.Second, Slow)
 	g.Eq("8080", Port)
 	g.Eq("/path/to/chrome", Bin)
+	g.Eq("stable", Version)
 	g.Eq("tmp", Dir)
 	g.Eq("http://test.com", URL)
 	g.NotNil(CDP.Println)
 	g.Eq(":0", Monitor)
 	g.Eq("localhost:8080", Proxy)
 	g.Eq(9981, LockPort)
+	g.Eq("ci", Profile)
+
+	parse("profile:dev")
+	g.Eq("dev", Profile)
 
 	parse("monitor=:1234")
 	g.Eq(":1234", Monitor)
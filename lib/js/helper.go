@@ -142,6 +142,13 @@ var InputColor = &Function{
 	Dependencies: []*Function{InputEvent},
 }
 
+// InputFiles ...
+var InputFiles = &Function{
+	Name:         "inputFiles",
+	Definition:   `function(e){const t=new DataTransfer;for(const n of e){const i=atob(n.data),a=new Uint8Array(i.length);for(let e=0;e<i.length;e++)a[e]=i.charCodeAt(e);t.items.add(new File([a],n.name,{type:n.mime}))}this.files=t.files,functions.inputEvent.call(this)}`,
+	Dependencies: []*Function{InputEvent},
+}
+
 // SelectText ...
 var SelectText = &Function{
 	Name:         "selectText",
@@ -299,6 +299,23 @@ const functions = {
 
     functions.inputEvent.call(this)
   },
+
+  inputFiles(files) {
+    const dt = new DataTransfer()
+
+    for (const file of files) {
+      const bin = atob(file.data)
+      const data = new Uint8Array(bin.length)
+      for (let i = 0; i < bin.length; i++) {
+        data[i] = bin.charCodeAt(i)
+      }
+      dt.items.add(new File([data], file.name, { type: file.mime }))
+    }
+
+    this.files = dt.files
+
+    functions.inputEvent.call(this)
+  },
   selectText(pattern) {
     const m = this.value.match(new RegExp(pattern))
     if (m) {
@@ -0,0 +1,360 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halicoming/rod/lib/utils"
+)
+
+var _ http.Handler = &Balancer{}
+
+// Balancer fronts multiple [Manager]s as a single one, so that the clients of [NewManaged] only need one url.
+// It checks the health and the [ManagerCapacity] of each manager via its defaults endpoint,
+// then routes each launch request to the healthy manager that has the most free slots.
+// The websocket of each session is proxied transparently to the manager.
+// The name of the client is forwarded to the managers via the [ClientHeaderName] header, the managers
+// should use the [ForwardedAuth] to trust it, or they will see all the clients of the balancer as one,
+// then the per-client policies like the [Manager.ClientQuota] have to be enforced by the balancer itself.
+//
+//	|    Client     |        Balancer         |          Managers           |
+//	| NewManaged(b) |-> http.ListenAndServe --|-> a.com:7317, b.com:7317 ... |
+type Balancer struct {
+	// Logger for key events
+	Logger utils.Logger
+
+	// Auth authenticates each request the same way as the [Manager.Auth], if it's nil all requests are allowed
+	// and the client name forwarded to the managers is the remote IP.
+	Auth func(*http.Request) (client string, err error)
+
+	// Interval between the health checks
+	Interval time.Duration
+
+	// Timeout of each health check
+	Timeout time.Duration
+
+	lock     sync.Mutex
+	backends []*balancerBackend
+	cancel   func()
+	done     chan struct{}
+}
+
+// BalancerBackend is the state of a manager of the [Balancer].
+type BalancerBackend struct {
+	URL      string          `json:"url"`
+	Healthy  bool            `json:"healthy"`
+	Error    string          `json:"error,omitempty"`
+	Checked  time.Time       `json:"checked"`
+	Capacity ManagerCapacity `json:"capacity"`
+
+	// Pending is the number of the launch requests routed to the manager since the last check.
+	Pending int `json:"pending"`
+}
+
+type balancerBackend struct {
+	BalancerBackend
+
+	u      *url.URL
+	header http.Header
+	proxy  *httputil.ReverseProxy
+}
+
+// MustNewBalancer is similar to [NewBalancer].
+func MustNewBalancer(managerURLs ...string) *Balancer {
+	b, err := NewBalancer(managerURLs...)
+	utils.E(err)
+	return b
+}
+
+// NewBalancer for the urls of the managers. If a manager requires the [BearerAuth], put the token in
+// the user info of its url the same way as [NewManaged], the token will be used for the health checks and
+// replace the one of the clients.
+// Call [Balancer.Start] to start the health checks, the managers are unhealthy before the first check.
+func NewBalancer(managerURLs ...string) (*Balancer, error) {
+	b := &Balancer{
+		Logger:   utils.LoggerQuiet,
+		Interval: 3 * time.Second,
+		Timeout:  5 * time.Second,
+	}
+
+	for i, raw := range managerURLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		header := http.Header{}
+		if u.User != nil {
+			token, has := u.User.Password()
+			if !has {
+				token = u.User.Username()
+			}
+			header.Set("Authorization", "Bearer "+token)
+			u.User = nil
+		}
+		u = toHTTP(*u)
+
+		be := &balancerBackend{BalancerBackend: BalancerBackend{URL: u.String()}, u: u, header: header}
+		be.proxy = b.newProxy(i, be)
+		b.backends = append(b.backends, be)
+	}
+
+	return b, nil
+}
+
+// Start the health checks in the background, it checks all the managers once before it returns.
+func (b *Balancer) Start() {
+	b.lock.Lock()
+	if b.cancel != nil {
+		b.lock.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.cancel = cancel
+	b.done = make(chan struct{})
+	b.lock.Unlock()
+
+	b.check(ctx)
+
+	go func() {
+		defer close(b.done)
+
+		t := time.NewTicker(b.Interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				b.check(ctx)
+			}
+		}
+	}()
+}
+
+// Stop the health checks.
+func (b *Balancer) Stop() {
+	b.lock.Lock()
+	cancel, done := b.cancel, b.done
+	b.cancel = nil
+	b.lock.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+// Backends returns the states of the managers.
+func (b *Balancer) Backends() []BalancerBackend {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	list := []BalancerBackend{}
+	for _, be := range b.backends {
+		list = append(list, be.BalancerBackend)
+	}
+	return list
+}
+
+// check all the managers at the same time.
+func (b *Balancer) check(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for _, be := range b.backends {
+		wg.Add(1)
+		go func(be *balancerBackend) {
+			defer wg.Done()
+
+			c, err := b.checkBackend(ctx, be)
+
+			b.lock.Lock()
+			defer b.lock.Unlock()
+
+			if err != nil && be.Healthy {
+				b.Logger.Println("Unhealthy", be.URL, err)
+			} else if err == nil && !be.Healthy {
+				b.Logger.Println("Healthy", be.URL, c)
+			}
+
+			be.Healthy = err == nil
+			be.Error = ""
+			if err != nil {
+				be.Error = err.Error()
+			}
+			be.Checked = time.Now()
+			be.Capacity = c
+			be.Pending = 0
+		}(be)
+	}
+	wg.Wait()
+}
+
+func (b *Balancer) checkBackend(ctx context.Context, be *balancerBackend) (ManagerCapacity, error) {
+	c := ManagerCapacity{}
+
+	ctx, cancel := context.WithTimeout(ctx, b.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, be.u.String(), nil)
+	if err != nil {
+		return c, err
+	}
+	req.Header = be.header.Clone()
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return c, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return c, fmt.Errorf("unexpected status: %s", res.Status)
+	}
+
+	raw := res.Header.Get(CapacityHeaderName)
+	if raw == "" {
+		return c, fmt.Errorf("missing the %s header, the manager may be outdated", CapacityHeaderName)
+	}
+
+	return c, json.Unmarshal([]byte(raw), &c)
+}
+
+func (b *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, err := b.authenticate(r)
+	if err != nil {
+		http.Error(w, "[rod-balancer] "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// overwrite the header from the client, so that it can't pretend to be others
+	r.Header.Set(ClientHeaderName, client)
+
+	be, err := b.route(r)
+	if err != nil {
+		code := http.StatusServiceUnavailable
+		if errors.Is(err, ErrSessionNotFound) {
+			code = http.StatusNotFound
+		}
+		b.Logger.Println("Reject", err)
+		http.Error(w, "[rod-balancer] "+err.Error(), code)
+		return
+	}
+
+	be.proxy.ServeHTTP(w, r)
+}
+
+// ServeBackends serves the json of the [Balancer.Backends], it requires the same auth as the launch requests.
+func (b *Balancer) ServeBackends(w http.ResponseWriter, r *http.Request) {
+	if _, err := b.authenticate(r); err != nil {
+		http.Error(w, "[rod-balancer] "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	utils.E(w.Write(utils.MustToJSONBytes(b.Backends())))
+}
+
+func (b *Balancer) authenticate(r *http.Request) (string, error) {
+	if b.Auth == nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, nil //nolint: nilerr
+		}
+		return host, nil
+	}
+	return b.Auth(r)
+}
+
+// route picks the manager for the request. The reattach requests go to the manager of the session.
+func (b *Balancer) route(r *http.Request) (*balancerBackend, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if id := r.Header.Get(SessionHeaderName); id != "" {
+		i, id, _ := strings.Cut(id, ".")
+		n, err := strconv.Atoi(i)
+		if err != nil || n < 0 || n >= len(b.backends) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, r.Header.Get(SessionHeaderName))
+		}
+		r.Header.Set(SessionHeaderName, id)
+		return b.backends[n], nil
+	}
+
+	var best *balancerBackend
+	for _, be := range b.backends {
+		if be.Healthy && (best == nil || balancerLess(be, best)) {
+			best = be
+		}
+	}
+
+	if best == nil {
+		return nil, ErrNoHealthyManager
+	}
+
+	if r.Header.Get("Upgrade") == "websocket" {
+		best.Pending++
+	}
+
+	return best, nil
+}
+
+// balancerLess returns true if a has more free slots than b, or the same free slots but less load.
+func balancerLess(a, b *balancerBackend) bool {
+	free := func(be *balancerBackend) float64 {
+		free := be.Capacity.Free()
+		if free < 0 {
+			return math.Inf(1)
+		}
+		return float64(free - be.Pending)
+	}
+	load := func(be *balancerBackend) int {
+		return be.Capacity.Sessions + be.Capacity.Queued + be.Pending
+	}
+
+	if fa, fb := free(a), free(b); fa != fb {
+		return fa > fb
+	}
+	return load(a) < load(b)
+}
+
+func (b *Balancer) newProxy(i int, be *balancerBackend) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(be.u)
+
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = be.u.Host
+		for k, v := range be.header {
+			r.Header[k] = v
+		}
+	}
+
+	// prefix the session id with the index of the manager, so that the reattach can be routed statelessly
+	proxy.ModifyResponse = func(res *http.Response) error {
+		if id := res.Header.Get(SessionHeaderName); id != "" {
+			res.Header.Set(SessionHeaderName, fmt.Sprintf("%d.%s", i, id))
+		}
+		return nil
+	}
+
+	proxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+		b.Logger.Println("Proxy error", be.URL, err)
+		http.Error(w, "[rod-balancer] "+err.Error(), http.StatusBadGateway)
+	}
+
+	return proxy
+}
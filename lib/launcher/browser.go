@@ -12,10 +12,10 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/halicoming/rod/lib/defaults"
 	"github.com/halicoming/rod/lib/utils"
-	"github.com/ysmood/fetchup"
 )
 
 // Host formats a revision number to a downloadable URL for the browser.
@@ -83,17 +83,42 @@ type Browser struct {
 	// Revision of the browser to use
 	Revision int
 
+	// Version of the Chrome for Testing build to use instead of the chromium [Browser.Revision].
+	// It can be a channel such as "stable", "beta", "dev", "canary", a milestone such as "120",
+	// or an exact version such as "120.0.6099.109". When it's set the [Browser.Hosts] are ignored.
+	Version string
+
+	// HeadlessShell uses the chrome-headless-shell build of the [Browser.Version].
+	HeadlessShell bool
+
+	// MetadataURL is the base url of the Chrome for Testing json endpoints, set it to use a local mirror.
+	// Default is [DefaultMetadataURL].
+	MetadataURL string
+
 	// RootDir to download different browser versions.
 	RootDir string
 
 	// Log to print output
 	Logger utils.Logger
 
-	// LockPort a tcp port to prevent race downloading. Default is 2968 .
+	// LockPort a tcp port to prevent race downloading. Default is 2978 . Set it to 0 to disable the lock.
 	LockPort int
 
+	// LockTimeout is the max time to wait for the [Browser.LockPort], the port may be used by an unrelated program.
+	// If it's zero, [DefaultLockTimeout] is used.
+	LockTimeout time.Duration
+
 	// HTTPClient to download the browser
 	HTTPClient *http.Client
+
+	// Manifest to verify the downloaded archives. If the url of an archive isn't in it,
+	// the archive won't be verified.
+	Manifest Manifest
+
+	// Progress is called each time a chunk of the archive is downloaded.
+	Progress func(DownloadProgress)
+
+	resolved *resolved
 }
 
 // NewBrowser with default values.
@@ -101,6 +126,7 @@ func NewBrowser() *Browser {
 	return &Browser{
 		Context:  context.Background(),
 		Revision: RevisionDefault,
+		Version:  defaults.Version,
 		Hosts:    []Host{HostGoogle, HostNPM, HostPlaywright},
 		RootDir:  DefaultBrowserDir,
 		Logger:   log.New(os.Stdout, "[launcher.Browser]", log.LstdFlags),
@@ -110,11 +136,18 @@ func NewBrowser() *Browser {
 
 // Dir to download the browser.
 func (lc *Browser) Dir() string {
+	if lc.Version != "" {
+		return filepath.Join(lc.RootDir, lc.build()+"-"+lc.exactVersion())
+	}
 	return filepath.Join(lc.RootDir, fmt.Sprintf("chromium-%d", lc.Revision))
 }
 
 // BinPath to download the browser executable.
 func (lc *Browser) BinPath() string {
+	if lc.Version != "" {
+		return filepath.Join(lc.Dir(), filepath.FromSlash(lc.cftBin()))
+	}
+
 	bin := map[string]string{
 		"darwin":  "Chromium.app/Contents/MacOS/Chromium",
 		"linux":   "chrome",
@@ -124,43 +157,43 @@ func (lc *Browser) BinPath() string {
 	return filepath.Join(lc.Dir(), filepath.FromSlash(bin))
 }
 
-// Download browser from the fastest host.
-// It will race downloading a TCP packet from each host and use the fastest host.
-func (lc *Browser) Download() error {
-	us := []string{}
-	for _, host := range lc.Hosts {
-		us = append(us, host(lc.Revision))
+// Get is a smart helper to get the browser executable path.
+// If [Browser.BinPath] is not valid it will auto download the browser to [Browser.BinPath],
+// the invalid one will be replaced after the new one is completely downloaded.
+// It only holds the [Browser.LockPort] while downloading. It updates the last use time of the browser
+// for [Browser.ListInstalled] if the dir is writable.
+func (lc *Browser) Get() (string, error) {
+	// Only the exact version can be validated without the metadata
+	if lc.Version != "" && !regVersion.MatchString(lc.Version) {
+		err := lc.Resolve()
+		if err != nil {
+			return "", err
+		}
 	}
 
-	dir := lc.Dir()
-
-	fu := fetchup.New(dir, us...)
-	fu.Ctx = lc.Context
-	fu.Logger = lc.Logger
-	if lc.HTTPClient != nil {
-		fu.HttpClient = lc.HTTPClient
+	if lc.Validate() != nil {
+		unlock, err := lc.lock()
+		if err != nil {
+			return "", err
+		}
+		defer unlock()
+
+		// another process may have downloaded it while we wait for the lock
+		if lc.Validate() != nil {
+			err = lc.Download()
+			if err != nil {
+				return "", err
+			}
+		}
 	}
 
-	err := fu.Fetch()
+	// the dir may be read-only or preinstalled, the stamp is only a hint for the Prune
+	err := lc.stamp()
 	if err != nil {
-		return fmt.Errorf("can't find a browser binary for your OS, the doc might help https://go-rod.github.io/#/compatibility?id=os : %w", err) //nolint: lll
-	}
-
-	return fetchup.StripFirstDir(dir)
-}
-
-// Get is a smart helper to get the browser executable path.
-// If [Browser.BinPath] is not valid it will auto download the browser to [Browser.BinPath].
-func (lc *Browser) Get() (string, error) {
-
-	if lc.Validate() == nil {
-		return lc.BinPath(), nil
+		lc.Logger.Println("failed to update the last use time:", err)
 	}
 
-	// Try to cleanup before downloading
-	_ = os.RemoveAll(lc.Dir())
-
-	return lc.BinPath(), lc.Download()
+	return lc.BinPath(), nil
 }
 
 // MustGet is similar with Get.
@@ -0,0 +1,401 @@
+package launcher
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// BundleManifest describes the browser in a bundle created by [Browser.Export].
+type BundleManifest struct {
+	// Name of the browser dir in the [Browser.RootDir], such as "chromium-1321438"
+	Name string `json:"name"`
+
+	Revision      int    `json:"revision,omitempty"`
+	Version       string `json:"version,omitempty"`
+	HeadlessShell bool   `json:"headlessShell,omitempty"`
+
+	// Platform the browser is built for, such as "linux/amd64"
+	Platform string `json:"platform"`
+
+	// Files maps the slash separated path of each regular file to its hex encoded SHA-256 checksum.
+	Files map[string]string `json:"files"`
+}
+
+const (
+	bundleManifestName = "rod-bundle.json"
+	bundleDir          = "browser/"
+)
+
+// ErrInvalidBundle is returned by [Browser.Import] when the bundle is malformed or doesn't match its manifest.
+var ErrInvalidBundle = errors.New("invalid browser bundle")
+
+// Export the downloaded browser of the [Browser.Revision] or [Browser.Version] as a tar.gz bundle to w.
+// The first entry of the bundle is the [BundleManifest], the file modes and symlinks are preserved.
+// Use [Browser.Import] to install the bundle on another machine without network.
+func (lc *Browser) Export(w io.Writer) error {
+	if lc.Version != "" && !regVersion.MatchString(lc.Version) && lc.current() == nil {
+		err := lc.Resolve()
+		if err != nil {
+			return err
+		}
+	}
+
+	dir := lc.Dir()
+
+	_, err := os.Stat(lc.BinPath())
+	if err != nil {
+		return err
+	}
+
+	m := &BundleManifest{
+		Name:          filepath.Base(dir),
+		Version:       lc.exactVersion(),
+		HeadlessShell: lc.HeadlessShell,
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		Files:         map[string]string{},
+	}
+	if lc.Version == "" {
+		m.Revision = lc.Revision
+	}
+
+	paths := []string{}
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel == lastUseStamp {
+			return nil
+		}
+
+		paths = append(paths, rel)
+
+		if d.Type().IsRegular() {
+			m.Files[rel], err = fileSHA256(p)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{Name: bundleManifestName, Mode: 0o644, Size: int64(len(data))})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range paths {
+		err = addToTar(tw, filepath.Join(dir, filepath.FromSlash(rel)), bundleDir+rel)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import the bundle created by [Browser.Export] to the [Browser.RootDir], it returns the installed browser.
+// The bundle is verified with its manifest and extracted to a temp dir before it replaces the existing one,
+// so that [Launcher.Launch] can find it without network when the revision or version matches.
+// It holds the [Browser.LockPort] while importing.
+func (lc *Browser) Import(r io.Reader) (*Installed, error) {
+	unlock, err := lc.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	err = os.MkdirAll(lc.RootDir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.MkdirTemp(lc.RootDir, ".install-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	m, err := readBundleManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := *lc
+	b.resolved = nil
+	b.Revision = m.Revision
+	b.Version = m.Version
+	b.HeadlessShell = m.HeadlessShell
+	if m.Revision != 0 {
+		b.Version = ""
+	}
+	if filepath.Base(b.Dir()) != m.Name {
+		return nil, fmt.Errorf("%w: the name %q doesn't match the revision or version", ErrInvalidBundle, m.Name)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		err = extractFromTar(tr, hdr, tmp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = verifyBundle(tmp, m)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := b.Dir()
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.installed()
+}
+
+func readBundleManifest(tr *tar.Reader) (*BundleManifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Name != bundleManifestName {
+		return nil, fmt.Errorf("%w: the first entry should be %s", ErrInvalidBundle, bundleManifestName)
+	}
+
+	var m BundleManifest
+	err = json.NewDecoder(tr).Decode(&m)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+	}
+
+	if !regChromium.MatchString(m.Name) && !regCfT.MatchString(m.Name) {
+		return nil, fmt.Errorf("%w: invalid name %q", ErrInvalidBundle, m.Name)
+	}
+
+	if platform := runtime.GOOS + "/" + runtime.GOARCH; m.Platform != platform {
+		return nil, fmt.Errorf("%w: the browser is built for %s, but current platform is %s",
+			ErrInvalidBundle, m.Platform, platform)
+	}
+
+	return &m, nil
+}
+
+func addToTar(tw *tar.Writer, p, name string) error {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		link = filepath.ToSlash(link)
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func extractFromTar(tr *tar.Reader, hdr *tar.Header, dir string) error {
+	rel, ok := strings.CutPrefix(strings.TrimSuffix(hdr.Name, "/"), bundleDir)
+	if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return fmt.Errorf("%w: invalid path %q", ErrInvalidBundle, hdr.Name)
+	}
+
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+
+	err := checkNoSymlink(dir, rel)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(p, hdr.FileInfo().Mode().Perm())
+
+	case tar.TypeSymlink:
+		// the link shouldn't point to outside of the bundle
+		if path.IsAbs(hdr.Linkname) || !filepath.IsLocal(filepath.FromSlash(path.Join(path.Dir(rel), hdr.Linkname))) {
+			return fmt.Errorf("%w: invalid symlink %q -> %q", ErrInvalidBundle, hdr.Name, hdr.Linkname)
+		}
+		return os.Symlink(filepath.FromSlash(hdr.Linkname), p)
+
+	case tar.TypeReg:
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+
+	default:
+		return fmt.Errorf("%w: unsupported entry type of %q", ErrInvalidBundle, hdr.Name)
+	}
+}
+
+// checkNoSymlink makes sure that the existing parts of the slash separated rel path in the dir aren't symlinks,
+// or the entry may be written outside of the dir via the chained links, such as "a/b -> .." and "a/b/c -> ..".
+func checkNoSymlink(dir, rel string) error {
+	p := dir
+	for _, part := range strings.Split(rel, "/") {
+		p = filepath.Join(p, part)
+
+		info, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: the path of %q has a symlink", ErrInvalidBundle, rel)
+		}
+	}
+	return nil
+}
+
+// verifyBundle checks every regular file in the dir against the manifest.
+func verifyBundle(dir string, m *BundleManifest) error {
+	count := 0
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		expected, has := m.Files[rel]
+		if !has {
+			return fmt.Errorf("%w: %s is not in the manifest", ErrInvalidBundle, rel)
+		}
+
+		actual, err := fileSHA256(p)
+		if err != nil {
+			return err
+		}
+		if actual != expected {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidBundle, rel, &ChecksumError{Expected: expected, Actual: actual})
+		}
+
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if count != len(m.Files) {
+		return fmt.Errorf("%w: expected %d files, got %d", ErrInvalidBundle, len(m.Files), count)
+	}
+
+	return nil
+}
+
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,224 @@
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DefaultMetadataURL is the base url of the Chrome for Testing json endpoints.
+// Ref: https://github.com/GoogleChromeLabs/chrome-for-testing#json-api-endpoints
+const DefaultMetadataURL = "https://googlechromelabs.github.io/chrome-for-testing"
+
+// The platform name used by Chrome for Testing.
+var cftPlatform = map[string]string{
+	"darwin_amd64":  "mac-x64",
+	"darwin_arm64":  "mac-arm64",
+	"linux_amd64":   "linux64",
+	"windows_386":   "win32",
+	"windows_amd64": "win64",
+}[runtime.GOOS+"_"+runtime.GOARCH]
+
+var (
+	regMilestone = regexp.MustCompile(`^\d+$`)
+	regVersion   = regexp.MustCompile(`^\d+\.\d+\.\d+\.\d+$`)
+)
+
+var cftChannels = map[string]string{
+	"stable": "Stable",
+	"beta":   "Beta",
+	"dev":    "Dev",
+	"canary": "Canary",
+}
+
+type cftDownload struct {
+	Platform string `json:"platform"`
+	URL      string `json:"url"`
+}
+
+type cftVersion struct {
+	Version   string                   `json:"version"`
+	Downloads map[string][]cftDownload `json:"downloads"`
+}
+
+// resolved version of the [Browser.Version].
+type resolved struct {
+	// the [Browser.Version] and build it's resolved from
+	from string
+
+	version string
+	url     string
+}
+
+// Resolve the [Browser.Version] to an exact version and its download url via the Chrome for Testing metadata.
+// It does nothing if the [Browser.Version] is empty.
+// [Browser.Download] and [Browser.Get] call it automatically, call it manually only if
+// you need the [Browser.Dir] or [Browser.BinPath] before them.
+func (lc *Browser) Resolve() error {
+	if lc.Version == "" {
+		return nil
+	}
+
+	if cftPlatform == "" {
+		return fmt.Errorf("chrome for testing doesn't support the platform: %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	var v *cftVersion
+	var err error
+
+	if channel, has := cftChannels[strings.ToLower(lc.Version)]; has {
+		v, err = lc.resolveChannel(channel)
+	} else if regMilestone.MatchString(lc.Version) || regVersion.MatchString(lc.Version) {
+		v, err = lc.resolveVersion()
+	} else {
+		return fmt.Errorf("invalid browser version %q, it should be a channel, milestone, or exact version", lc.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, d := range v.Downloads[lc.build()] {
+		if d.Platform == cftPlatform {
+			lc.resolved = &resolved{from: lc.resolveKey(), version: v.Version, url: d.URL}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no %s download of version %s for platform %s", lc.build(), v.Version, cftPlatform)
+}
+
+func (lc *Browser) resolveChannel(channel string) (*cftVersion, error) {
+	var res struct {
+		Channels map[string]*cftVersion `json:"channels"`
+	}
+	err := lc.getMetadata("last-known-good-versions-with-downloads.json", &res)
+	if err != nil {
+		return nil, err
+	}
+
+	v, has := res.Channels[channel]
+	if !has {
+		return nil, fmt.Errorf("channel %s not found in the metadata", channel)
+	}
+	return v, nil
+}
+
+// resolveVersion finds the exact version, or the latest version of the milestone.
+func (lc *Browser) resolveVersion() (*cftVersion, error) {
+	var res struct {
+		Versions []*cftVersion `json:"versions"`
+	}
+	err := lc.getMetadata("known-good-versions-with-downloads.json", &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *cftVersion
+	for _, v := range res.Versions {
+		if len(v.Downloads[lc.build()]) == 0 {
+			continue
+		}
+
+		if v.Version == lc.Version ||
+			strings.HasPrefix(v.Version, lc.Version+".") && (found == nil || compareVersion(v.Version, found.Version) > 0) {
+			found = v
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("browser version %s not found in the metadata", lc.Version)
+	}
+	return found, nil
+}
+
+func (lc *Browser) getMetadata(name string, v interface{}) error {
+	base := lc.MetadataURL
+	if base == "" {
+		base = DefaultMetadataURL
+	}
+
+	req, err := http.NewRequestWithContext(lc.Context, http.MethodGet, strings.TrimSuffix(base, "/")+"/"+name, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := lc.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get the metadata %s: %s", req.URL, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// build is the name of the Chrome for Testing build to download.
+func (lc *Browser) build() string {
+	if lc.HeadlessShell {
+		return "chrome-headless-shell"
+	}
+	return "chrome"
+}
+
+func (lc *Browser) resolveKey() string {
+	return lc.build() + " " + lc.Version
+}
+
+// current returns the resolved result if it's still up to date, or nil.
+func (lc *Browser) current() *resolved {
+	if lc.Version == "" || lc.resolved == nil || lc.resolved.from != lc.resolveKey() {
+		return nil
+	}
+	return lc.resolved
+}
+
+// exactVersion returns the resolved version, or the [Browser.Version] if it's not resolved yet.
+func (lc *Browser) exactVersion() string {
+	if r := lc.current(); r != nil {
+		return r.version
+	}
+	return lc.Version
+}
+
+func (lc *Browser) cftBin() string {
+	if lc.HeadlessShell {
+		if runtime.GOOS == "windows" {
+			return "chrome-headless-shell.exe"
+		}
+		return "chrome-headless-shell"
+	}
+
+	return map[string]string{
+		"darwin":  "Google Chrome for Testing.app/Contents/MacOS/Google Chrome for Testing",
+		"linux":   "chrome",
+		"windows": "chrome.exe",
+	}[runtime.GOOS]
+}
+
+// compareVersion compares dot separated numeric versions, such as "120.0.6099.109".
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package launcher
+
+import (
+	"errors"
+	"os"
+)
+
+func cloneFile(_, _ *os.File) error {
+	return errors.ErrUnsupported
+}
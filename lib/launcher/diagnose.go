@@ -0,0 +1,157 @@
+package launcher
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Failure is a common reason why the browser fails to launch or dies.
+type Failure string
+
+const (
+	// FailureUnknown means the output doesn't match any known failure.
+	FailureUnknown Failure = ""
+
+	// FailureMissingLibrary means the shared libraries required by the browser are not installed.
+	FailureMissingLibrary Failure = "missing-shared-library"
+
+	// FailureSandbox means the OS doesn't allow the browser to create its sandbox.
+	FailureSandbox Failure = "sandbox"
+
+	// FailureProfileLock means another browser process is using the same user data dir.
+	FailureProfileLock Failure = "profile-lock"
+
+	// FailureNoDisplay means the headful browser can't find a display server.
+	FailureNoDisplay Failure = "no-display"
+
+	// FailureCrash means the browser crashed.
+	FailureCrash Failure = "crash"
+)
+
+// Hint to fix the failure.
+func (f Failure) Hint() string {
+	return map[Failure]string{
+		FailureMissingLibrary: "the shared libraries of the browser are missing, the doc might help https://go-rod.github.io/#/compatibility?id=os",
+		FailureSandbox:        "the browser can't create its sandbox, try Launcher.NoSandbox(true) or enable the user namespaces of the OS",
+		FailureProfileLock:    "the user data dir is being used by another browser, close it or use a different Launcher.UserDataDir",
+		FailureNoDisplay:      "no display server is found, use the headless mode, Launcher.XvfbScreen or Launcher.XVFB",
+		FailureCrash:          "the browser crashed",
+	}[f]
+}
+
+var failureSignatures = []struct {
+	failure Failure
+	reg     *regexp.Regexp
+}{
+	{FailureMissingLibrary, regexp.MustCompile(`error while loading shared libraries`)},
+	{FailureSandbox, regexp.MustCompile(`No usable sandbox|without --no-sandbox|setuid sandbox|Failed to move to new namespace`)},
+	{FailureProfileLock, regexp.MustCompile(`profile appears to be in use|Failed to create .*SingletonLock|ProcessSingleton`)},
+	{FailureNoDisplay, regexp.MustCompile(`Missing X server|cannot open display|Unable to open X display`)},
+	{FailureCrash, regexp.MustCompile(`Received signal \d+|Check failed:|FATAL:`)},
+}
+
+// DetectFailure returns the first known failure found in the browser output.
+func DetectFailure(output string) Failure {
+	for _, s := range failureSignatures {
+		if s.reg.MatchString(output) {
+			return s.failure
+		}
+	}
+	return FailureUnknown
+}
+
+// LaunchError is returned when the browser fails to launch or exits unexpectedly.
+type LaunchError struct {
+	// Bin is the path of the browser executable
+	Bin string
+
+	// Args passed to the browser
+	Args []string
+
+	// Failure detected from the Output
+	Failure Failure
+
+	// Output is the recent stdout and stderr of the browser
+	Output string
+
+	// Exit status of the browser process, it's nil if the process is still running.
+	Exit *os.ProcessState
+
+	// Err is the underlying error, such as the error of starting the process
+	Err error
+}
+
+func (e *LaunchError) Error() string {
+	msg := "[launcher] Failed to get the debug url"
+	if e.Failure != FailureUnknown {
+		msg = "[launcher] Failed to launch the browser, " + e.Failure.Hint()
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Exit != nil {
+		msg += fmt.Sprintf(" (%s)", e.Exit)
+	}
+	return msg + ": " + e.Output
+}
+
+// Unwrap ...
+func (e *LaunchError) Unwrap() error {
+	return e.Err
+}
+
+// Is interface
+func (e *LaunchError) Is(err error) bool {
+	_, ok := err.(*LaunchError)
+	return ok
+}
+
+// ExitStatus of the browser process.
+type ExitStatus struct {
+	// Code is the exit code, it's -1 if the process is terminated by a signal.
+	Code int
+
+	// Signal that terminated the process, such as "killed". It's empty if the process exited by itself.
+	Signal string
+}
+
+// DefaultOutputSize is the max bytes of the recent browser output kept by the launcher.
+const DefaultOutputSize = 64 * 1024
+
+// ring buffer that keeps the last size bytes written to it.
+type ring struct {
+	lock sync.Mutex
+	buf  []byte
+	size int
+}
+
+func newRing(size int) *ring {
+	return &ring{size: size}
+}
+
+// Write interface
+func (r *ring) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n := len(p)
+	if n >= r.size {
+		r.buf = append(r.buf[:0], p[n-r.size:]...)
+		return n, nil
+	}
+
+	if over := len(r.buf) + n - r.size; over > 0 {
+		r.buf = append(r.buf[:0], r.buf[over:]...)
+	}
+	r.buf = append(r.buf, p...)
+
+	return n, nil
+}
+
+func (r *ring) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return string(r.buf)
+}
@@ -0,0 +1,237 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Discovered browser installed on the system.
+type Discovered struct {
+	// Bin is the absolute path of the browser executable.
+	Bin string
+
+	// Product of the browser, such as "chrome", "chromium", "edge", "brave", or "chrome-headless-shell".
+	Product string
+
+	// Channel of the browser, such as "stable", "beta", "dev", or "canary". It's empty if the browser has no channel.
+	Channel string
+
+	// Version such as "120.0.6099.109". It's empty if it can't be detected.
+	Version string
+}
+
+// Requirement to select a browser from the [Discover] result, the empty fields match anything.
+type Requirement struct {
+	// Product such as "chrome"
+	Product string
+
+	// Channel such as "stable"
+	Channel string
+
+	// MinVersion such as "120" or "120.0.6099.109"
+	MinVersion string
+}
+
+// Match returns true if the browser satisfies the requirement.
+func (r Requirement) Match(d *Discovered) bool {
+	if r.Product != "" && !strings.EqualFold(r.Product, d.Product) {
+		return false
+	}
+	if r.Channel != "" && !strings.EqualFold(r.Channel, d.Channel) {
+		return false
+	}
+	if r.MinVersion != "" && (d.Version == "" || compareVersion(d.Version, r.MinVersion) < 0) {
+		return false
+	}
+	return true
+}
+
+// known installation of a browser, the path can be a name on PATH or an absolute path.
+type known struct {
+	path    string
+	product string
+	channel string
+}
+
+var knownBrowsers = map[string][]known{
+	"darwin": append(append(
+		macApps("chrome", "Google Chrome", "Google Chrome Beta", "Google Chrome Dev", "Google Chrome Canary"),
+		macApps("edge", "Microsoft Edge", "Microsoft Edge Beta", "Microsoft Edge Dev", "Microsoft Edge Canary")...),
+		append(macApps("brave", "Brave Browser", "Brave Browser Beta", "", "Brave Browser Nightly"),
+			known{"/Applications/Chromium.app/Contents/MacOS/Chromium", "chromium", ""},
+			known{"google-chrome", "chrome", ""},
+			known{"chromium", "chromium", ""},
+			known{"chrome-headless-shell", "chrome-headless-shell", ""},
+		)...),
+	"linux": {
+		{"google-chrome", "chrome", ""},
+		{"google-chrome-stable", "chrome", "stable"},
+		{"google-chrome-beta", "chrome", "beta"},
+		{"google-chrome-unstable", "chrome", "dev"},
+		{"chrome", "chrome", ""},
+		{"chromium", "chromium", ""},
+		{"chromium-browser", "chromium", ""},
+		{"/snap/bin/chromium", "chromium", ""},
+		{"/data/data/com.termux/files/usr/bin/chromium-browser", "chromium", ""},
+		{"microsoft-edge", "edge", ""},
+		{"microsoft-edge-stable", "edge", "stable"},
+		{"microsoft-edge-beta", "edge", "beta"},
+		{"microsoft-edge-dev", "edge", "dev"},
+		{"brave-browser", "brave", ""},
+		{"brave-browser-stable", "brave", "stable"},
+		{"brave-browser-beta", "brave", "beta"},
+		{"brave-browser-nightly", "brave", "canary"},
+		{"chrome-headless-shell", "chrome-headless-shell", ""},
+	},
+	"openbsd": {
+		{"chrome", "chrome", ""},
+		{"chromium", "chromium", ""},
+	},
+	"windows": append(append(append(
+		windowsApps("chrome", `Google\Chrome%s\Application\chrome.exe`, " Beta", " Dev", " SxS"),
+		windowsApps("edge", `Microsoft\Edge%s\Application\msedge.exe`, " Beta", " Dev", " SxS")...),
+		windowsApps("brave", `BraveSoftware\Brave-Browser%s\Application\brave.exe`, "-Beta", "", "-Nightly")...),
+		append(expandKnown(known{`Chromium\Application\chrome.exe`, "chromium", ""}),
+			known{"chrome", "chrome", ""},
+			known{"msedge", "edge", ""},
+			known{"chrome-headless-shell", "chrome-headless-shell", ""},
+		)...),
+}[runtime.GOOS]
+
+// macApps returns the known app paths of the stable, beta, dev, and canary channels.
+func macApps(product string, names ...string) []known {
+	list := []known{}
+	for i, name := range names {
+		if name != "" {
+			list = append(list, known{
+				fmt.Sprintf("/Applications/%s.app/Contents/MacOS/%s", name, name),
+				product, channelNames[i],
+			})
+		}
+	}
+	return list
+}
+
+// windowsApps returns the known exe paths of the stable, beta, dev, and canary channels.
+func windowsApps(product, format string, suffixes ...string) []known {
+	list := expandKnown(known{fmt.Sprintf(format, ""), product, "stable"})
+	for i, suffix := range suffixes {
+		if suffix != "" {
+			list = append(list, expandKnown(known{fmt.Sprintf(format, suffix), product, channelNames[i+1]})...)
+		}
+	}
+	return list
+}
+
+func expandKnown(k known) []known {
+	list := []known{}
+	for _, p := range expandWindowsExePaths(k.path) {
+		list = append(list, known{p, k.product, k.channel})
+	}
+	return list
+}
+
+var channelNames = []string{"stable", "beta", "dev", "canary"}
+
+var regChannelOutput = regexp.MustCompile(`(?i)\b(beta|dev|canary|unstable|nightly)\s*$`)
+
+// Discover all the Chrome, Chromium, Edge, Brave and chrome-headless-shell installations
+// on PATH and in the well-known paths of current operating system, the newest version comes first.
+// Unlike [LookPath] it runs each browser with "--version" to detect the version, so it's slower.
+func Discover() []*Discovered {
+	list := []*Discovered{}
+	seen := map[string]bool{}
+
+	for _, k := range knownBrowsers {
+		bin, err := exec.LookPath(k.path)
+		if err != nil {
+			continue
+		}
+
+		bin, err = filepath.Abs(bin)
+		if err != nil {
+			continue
+		}
+
+		real, err := filepath.EvalSymlinks(bin)
+		if err != nil || seen[real] {
+			continue
+		}
+		seen[real] = true
+
+		list = append(list, &Discovered{Bin: bin, Product: k.product, Channel: k.channel})
+	}
+
+	wg := sync.WaitGroup{}
+	for _, d := range list {
+		wg.Add(1)
+		go func(d *Discovered) {
+			defer wg.Done()
+			d.detect()
+		}(d)
+	}
+	wg.Wait()
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return compareVersion(list[i].Version, list[j].Version) > 0
+	})
+
+	return list
+}
+
+// FindBrowser returns the newest browser from [Discover] that satisfies the requirement.
+func FindBrowser(r Requirement) (*Discovered, error) {
+	list := Discover()
+	for _, d := range list {
+		if r.Match(d) {
+			return d, nil
+		}
+	}
+
+	found := []string{}
+	for _, d := range list {
+		found = append(found, fmt.Sprintf("%s %s %s (%s)", d.Product, d.Channel, d.Version, d.Bin))
+	}
+	return nil, fmt.Errorf("%w for %+v, found: %v", ErrBrowserNotFound, r, found)
+}
+
+func (d *Discovered) detect() {
+	if runtime.GOOS == "windows" {
+		// The browsers on Windows don't print the version, but they have a sub dir named after the version
+		d.Version = installedVersion(filepath.Dir(d.Bin), d.Bin)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), binVersionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, d.Bin, "--version").Output()
+	if err != nil {
+		return
+	}
+
+	line := strings.TrimSpace(string(out))
+	d.Version = regVersionOutput.FindString(line)
+
+	if d.Channel != "" || d.Product == "chromium" || d.Product == "chrome-headless-shell" {
+		return
+	}
+
+	d.Channel = "stable"
+	if m := regChannelOutput.FindStringSubmatch(line); m != nil {
+		d.Channel = map[string]string{
+			"unstable": "dev",
+			"nightly":  "canary",
+		}[strings.ToLower(m[1])]
+		if d.Channel == "" {
+			d.Channel = strings.ToLower(m[1])
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package launcher
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ysmood/fetchup"
+)
+
+// Manifest maps the download url of a browser archive to the hex encoded SHA-256 checksum of the archive.
+type Manifest map[string]string
+
+// ParseManifest parses lines in the format of the output of "sha256sum", such as:
+//
+//	2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  https://example.com/chrome-linux.zip
+//
+// Empty lines and lines start with "#" are ignored.
+func ParseManifest(r io.Reader) (Manifest, error) {
+	m := Manifest{}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid manifest line %d: %q", n, line)
+		}
+
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 checksum on manifest line %d: %q", n, fields[0])
+		}
+
+		m[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+
+	return m, s.Err()
+}
+
+// DownloadProgress of a browser archive.
+type DownloadProgress struct {
+	URL string
+
+	// Downloaded bytes, including the bytes resumed from the previous partial download.
+	Downloaded int64
+
+	// Total bytes of the archive, -1 if it's unknown.
+	Total int64
+}
+
+// DownloadError lists the reason of each host that failed to download the browser.
+type DownloadError struct {
+	Revision int
+
+	// Version is the resolved [Browser.Version], it's empty if the revision is used.
+	Version string
+
+	Hosts []*HostError
+}
+
+// Error interface.
+func (e *DownloadError) Error() string {
+	b := &strings.Builder{}
+	b.WriteString("can't find a browser binary for your OS, the doc might help https://go-rod.github.io/#/compatibility?id=os : ")
+	if e.Version == "" {
+		fmt.Fprintf(b, "failed to download revision %d", e.Revision)
+	} else {
+		fmt.Fprintf(b, "failed to download version %s", e.Version)
+	}
+
+	if len(e.Hosts) == 0 {
+		b.WriteString(", no host available")
+	}
+	for _, h := range e.Hosts {
+		fmt.Fprintf(b, "\n  %s", h.Error())
+	}
+
+	return b.String()
+}
+
+// Unwrap interface.
+func (e *DownloadError) Unwrap() []error {
+	list := []error{}
+	for _, h := range e.Hosts {
+		list = append(list, h)
+	}
+	return list
+}
+
+// Is interface.
+func (e *DownloadError) Is(err error) bool {
+	_, ok := err.(*DownloadError)
+	return ok
+}
+
+// HostError is the reason why downloading from the URL failed.
+type HostError struct {
+	URL string
+	Err error
+}
+
+// Error interface.
+func (e *HostError) Error() string {
+	return fmt.Sprintf("%s: %v", e.URL, e.Err)
+}
+
+// Unwrap interface.
+func (e *HostError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumError is returned when the SHA-256 of the archive doesn't match the [Browser.Manifest].
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+// Error interface.
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("SHA-256 checksum mismatch, expected %s, got %s", e.Expected, e.Actual)
+}
+
+// Is interface.
+func (e *ChecksumError) Is(err error) bool {
+	_, ok := err.(*ChecksumError)
+	return ok
+}
+
+// Download browser from the fastest host.
+// It will race downloading a TCP packet from each host and use the fastest host,
+// if it fails the other hosts will be tried one by one.
+// A partial archive left by the previous interrupted download will be resumed if the host supports range requests.
+// The archive is verified with the [Browser.Manifest] and extracted to a temp dir,
+// then the temp dir is renamed to [Browser.Dir], so the [Browser.Dir] is either complete or absent.
+func (lc *Browser) Download() error {
+	if lc.current() == nil {
+		err := lc.Resolve()
+		if err != nil {
+			return err
+		}
+	}
+
+	e := &DownloadError{Revision: lc.Revision}
+	if r := lc.current(); r != nil {
+		e.Version = r.version
+	}
+
+	for _, u := range lc.candidates() {
+		err := lc.download(u)
+		if err == nil {
+			return nil
+		}
+
+		if lc.Context.Err() != nil {
+			return lc.Context.Err()
+		}
+
+		lc.Logger.Println(err)
+		e.Hosts = append(e.Hosts, &HostError{URL: u, Err: err})
+	}
+
+	return e
+}
+
+// candidates returns the urls in the order to try, the ones with a partial download come first,
+// then the fastest one, then the rest.
+func (lc *Browser) candidates() []string {
+	us := []string{}
+	if r := lc.current(); r != nil {
+		us = append(us, r.url)
+	} else {
+		for _, host := range lc.Hosts {
+			us = append(us, host(lc.Revision))
+		}
+	}
+
+	first := ""
+	for _, u := range us {
+		if _, err := os.Stat(lc.partPath(u)); err == nil {
+			first = u
+			break
+		}
+	}
+
+	if first == "" && len(us) > 1 {
+		fu := fetchup.New("", us...)
+		fu.Ctx = lc.Context
+		fu.Logger = lc.Logger
+		fu.HttpClient = lc.httpClient()
+		first = fu.FastestURL()
+	}
+
+	if first == "" {
+		return us
+	}
+
+	list := []string{first}
+	for _, u := range us {
+		if u != first {
+			list = append(list, u)
+		}
+	}
+	return list
+}
+
+func (lc *Browser) download(u string) error {
+	part := lc.partPath(u)
+
+	err := lc.fetch(u, part)
+	if err != nil {
+		return err
+	}
+
+	err = lc.verify(u, part)
+	if err == nil {
+		err = lc.install(u, part)
+	}
+
+	// the partial archive is either complete or broken now, we don't need it anymore
+	_ = os.Remove(part)
+
+	return err
+}
+
+// partPath is where the archive is downloaded to before it's extracted.
+func (lc *Browser) partPath(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return filepath.Join(lc.RootDir, fmt.Sprintf(".%s-%x.part", filepath.Base(lc.Dir()), sum[:4]))
+}
+
+func (lc *Browser) httpClient() *http.Client {
+	if lc.HTTPClient != nil {
+		return lc.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (lc *Browser) fetch(u, part string) error {
+	lc.Logger.Println(fetchup.EventDownload, u)
+
+	err := os.MkdirAll(lc.RootDir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(lc.Context, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	res, err := lc.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	total := int64(-1)
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		// the host doesn't support range requests, start over
+		if offset > 0 {
+			err = f.Truncate(0)
+			if err != nil {
+				return err
+			}
+			offset, err = f.Seek(0, io.SeekStart)
+			if err != nil {
+				return err
+			}
+		}
+		if res.ContentLength >= 0 {
+			total = res.ContentLength
+		}
+
+	case http.StatusPartialContent:
+		var start, end int64
+		_, err = fmt.Sscanf(res.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total)
+		if err != nil || start != offset {
+			return fmt.Errorf("invalid Content-Range %q for the resumed download", res.Header.Get("Content-Range"))
+		}
+		lc.Logger.Println(fetchup.EventDownload, "resume from", offset)
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial archive is already complete
+		return nil
+
+	default:
+		return fmt.Errorf("unexpected http status: %s", res.Status)
+	}
+
+	p := &progress{
+		browser:  lc,
+		progress: DownloadProgress{URL: u, Downloaded: offset, Total: total},
+	}
+
+	_, err = io.Copy(io.MultiWriter(f, p), res.Body)
+	if err != nil {
+		return err
+	}
+
+	if total >= 0 && p.progress.Downloaded != total {
+		return fmt.Errorf("incomplete download, expected %d bytes, got %d", total, p.progress.Downloaded)
+	}
+
+	return nil
+}
+
+func (lc *Browser) verify(u, part string) error {
+	expected, has := lc.Manifest[u]
+	if !has {
+		return nil
+	}
+
+	actual, err := fileSHA256(part)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		return &ChecksumError{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// install extracts the archive to a temp dir, then renames it to the [Browser.Dir].
+func (lc *Browser) install(u, part string) error {
+	tmp, err := os.MkdirTemp(lc.RootDir, ".install-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	f, err := os.Open(part)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	fu := fetchup.New(tmp)
+	fu.Ctx = lc.Context
+	fu.Logger = lc.Logger
+
+	name := u
+	if parsed, err := url.Parse(u); err == nil {
+		name = path.Base(parsed.Path)
+	}
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		name = strings.TrimSuffix(name, ".gz")
+		r, err = gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		err = fu.UnZip(r)
+	case strings.HasSuffix(name, ".tar"):
+		err = fu.UnTar(r)
+	default:
+		err = errors.New("unsupported archive format: " + name)
+	}
+	if err != nil {
+		return err
+	}
+
+	root, err := archiveRoot(tmp)
+	if err != nil {
+		return err
+	}
+
+	dir := lc.Dir()
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(root, dir)
+	if err != nil {
+		return err
+	}
+
+	lc.Logger.Println(fetchup.EventDownloaded, dir)
+
+	return nil
+}
+
+// archiveRoot returns the only top level dir of the extracted archive, such as "chrome-linux".
+// If there are multiple top level entries, the dir itself is returned.
+func archiveRoot(dir string) (string, error) {
+	list, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(list) == 1 && list[0].IsDir() {
+		return filepath.Join(dir, list[0].Name()), nil
+	}
+
+	if len(list) == 0 {
+		return "", errors.New("the archive is empty")
+	}
+
+	return dir, nil
+}
+
+type progress struct {
+	browser  *Browser
+	progress DownloadProgress
+	last     time.Time
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.progress.Downloaded += int64(len(b))
+
+	if p.browser.Progress != nil {
+		p.browser.Progress(p.progress)
+	}
+
+	if time.Since(p.last) >= time.Second {
+		p.last = time.Now()
+
+		if p.progress.Total > 0 {
+			p.browser.Logger.Println(fetchup.EventProgress, fmt.Sprintf("%02d%%", p.progress.Downloaded*100/p.progress.Total))
+		} else {
+			p.browser.Logger.Println(fetchup.EventProgress, fmt.Sprintf("%.3fMB", float64(p.progress.Downloaded)/1024/1024))
+		}
+	}
+
+	return len(b), nil
+}
@@ -4,3 +4,31 @@ import "errors"
 
 // ErrAlreadyLaunched is an error that indicates the launcher has already been launched.
 var ErrAlreadyLaunched = errors.New("already launched")
+
+// ErrBrowserNotFound is returned by [FindBrowser] when no discovered browser satisfies the requirement.
+var ErrBrowserNotFound = errors.New("browser not found")
+
+// ErrUnauthorized is returned by the [Manager.Auth] when the client isn't allowed.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// ErrFlagNotAllowed is returned by the [Manager] when the client sets a flag that isn't in the [Manager.AllowedFlags].
+var ErrFlagNotAllowed = errors.New("flag not allowed")
+
+// ErrQuotaExceeded is returned by the [Manager] when the client has reached the [Manager.ClientQuota].
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrQueueTimeout is returned by the [Manager] when the launch request waits longer than the [Manager.QueueTimeout].
+var ErrQueueTimeout = errors.New("queue timeout")
+
+// ErrNoHealthyManager is returned by the [Balancer] when all of its managers are unhealthy.
+var ErrNoHealthyManager = errors.New("no healthy manager")
+
+// ErrSessionNotFound is returned by the [Manager] when the session doesn't exist or has ended.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrTetherNotFound is returned by the [Manager] when the tethering connection isn't accepted by the browser
+// of the session, or it's already opened.
+var ErrTetherNotFound = errors.New("tethering connection not found")
+
+// ErrXvfb is returned when the Xvfb of [Launcher.XvfbScreen] fails to start or isn't running.
+var ErrXvfb = errors.New("xvfb failed")
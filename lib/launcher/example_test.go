@@ -16,6 +16,27 @@ func Example_use_system_browser() {
 	}
 }
 
+func Example_select_system_browser() {
+	// Pick the newest stable Chrome that is at least 120, so that every machine runs the same kind of browser.
+	d, err := launcher.FindBrowser(launcher.Requirement{Product: "chrome", Channel: "stable", MinVersion: "120"})
+	utils.E(err)
+
+	u := launcher.New().Bin(d.Bin).MustLaunch()
+	rod.New().ControlURL(u).MustConnect()
+}
+
+func Example_supervisor() {
+	// Keep the browser running, restart it after crashes and limit its memory to 2GB on Linux.
+	s := launcher.NewSupervisor(launcher.New())
+	s.Limits = launcher.Limits{Memory: 2 << 30}
+	s.MustStart()
+	defer s.Stop()
+
+	// The client reconnects to the restarted browser automatically.
+	browser := rod.New().Client(s.MustClient()).MustConnect()
+	browser.MustPage("https://example.com")
+}
+
 func Example_print_browser_CLI_output() {
 	// Pipe the browser stderr and stdout to os.Stdout .
 	u := launcher.New().Logger(os.Stdout).MustLaunch()
@@ -0,0 +1,244 @@
+package flags
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type of the flag value.
+type Type int
+
+const (
+	// TypeBool flag has no value, such as "--no-sandbox"
+	TypeBool Type = iota
+
+	// TypeString flag has a single value
+	TypeString
+
+	// TypeInt flag has a single integer value
+	TypeInt
+
+	// TypeFloat flag has a single number value
+	TypeFloat
+
+	// TypeList flag has comma separated values, it can be empty
+	TypeList
+
+	// TypeSize flag has two comma separated integers, such as "--window-size=1280,720"
+	TypeSize
+
+	// TypeEnum flag has one of the [Info.Values], the empty value means the flag has no value
+	TypeEnum
+)
+
+// Info about a flag in the [Catalog].
+type Info struct {
+	Name        Flag
+	Type        Type
+	Description string
+
+	// Values for the [TypeEnum]
+	Values []string
+
+	// Conflicts are the flags that contradict this flag
+	Conflicts []Flag
+
+	// Check the values beyond the type, such as the format of a path
+	Check func(values []string) error
+}
+
+// Lookup the flag in the [Catalog].
+func Lookup(f Flag) (*Info, bool) {
+	i, has := Catalog[f.NormalizeFlag()]
+	return i, has
+}
+
+// Validate the values of the flag.
+func (i *Info) Validate(values []string) error {
+	n := len(values)
+	str := strings.Join(values, ",")
+
+	switch i.Type {
+	case TypeBool:
+		if n != 0 {
+			return fmt.Errorf("should have no value, got %q", str)
+		}
+	case TypeString:
+		if n != 1 {
+			return fmt.Errorf("should have one value, got %q", str)
+		}
+	case TypeInt:
+		if n != 1 {
+			return fmt.Errorf("should have one integer, got %q", str)
+		}
+		if _, err := strconv.Atoi(values[0]); err != nil {
+			return fmt.Errorf("should be an integer, got %q", values[0])
+		}
+	case TypeFloat:
+		if n != 1 {
+			return fmt.Errorf("should have one number, got %q", str)
+		}
+		if _, err := strconv.ParseFloat(values[0], 64); err != nil {
+			return fmt.Errorf("should be a number, got %q", values[0])
+		}
+	case TypeSize:
+		parts := strings.Split(str, ",")
+		ok := len(parts) == 2
+		for _, v := range parts {
+			if _, err := strconv.Atoi(strings.TrimSpace(v)); err != nil {
+				ok = false
+			}
+		}
+		if !ok {
+			return fmt.Errorf(`should be like "1280,720", got %q`, str)
+		}
+	case TypeEnum:
+		if n > 1 || (n == 1 && !slices.Contains(i.Values, values[0])) {
+			return fmt.Errorf("should be one of %q, got %q", i.Values, str)
+		}
+	}
+
+	if i.Check != nil {
+		return i.Check(values)
+	}
+
+	return nil
+}
+
+// Problem of a flag found by [Validate].
+type Problem struct {
+	Flag Flag
+	Msg  string
+
+	// Warning is true if the problem won't stop the launch, such as an unknown flag,
+	// because the [Catalog] can't cover all the flags of the browser.
+	Warning bool
+}
+
+func (p *Problem) Error() string {
+	if p.Flag == Arguments {
+		return p.Msg
+	}
+	return fmt.Sprintf("flag --%s: %s", p.Flag, p.Msg)
+}
+
+// Problems found by [Validate].
+type Problems []*Problem
+
+func (ps Problems) Error() string {
+	list := []string{}
+	for _, p := range ps {
+		list = append(list, p.Error())
+	}
+	return strings.Join(list, "; ")
+}
+
+// Errors returns the problems that are not warnings.
+func (ps Problems) Errors() Problems {
+	list := Problems{}
+	for _, p := range ps {
+		if !p.Warning {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Validate the flags against the [Catalog], it checks the unknown flags, the values, and the conflicts.
+func Validate(flags map[Flag][]string) Problems {
+	names := []string{}
+	for f := range flags {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+
+	list := Problems{}
+	for _, name := range names {
+		f := Flag(name)
+		if f == Arguments {
+			continue
+		}
+
+		info, has := Lookup(f)
+		if !has {
+			msg := "unknown flag"
+			if s := suggest(f); s != "" {
+				msg += fmt.Sprintf(", did you mean --%s?", s)
+			}
+			list = append(list, &Problem{Flag: f, Msg: msg, Warning: true})
+			continue
+		}
+
+		if err := info.Validate(flags[f]); err != nil {
+			list = append(list, &Problem{Flag: f, Msg: err.Error()})
+		}
+
+		for _, c := range info.Conflicts {
+			// the conflicts are symmetric, report each pair once
+			if _, has := flags[c]; has && string(c) > name {
+				list = append(list, &Problem{Flag: f, Msg: fmt.Sprintf("conflicts with --%s", c)})
+			}
+		}
+	}
+
+	return list
+}
+
+// suggest the known flag that is most similar to f.
+func suggest(f Flag) Flag {
+	best, limit := Flag(""), len(f)/3+1
+	for name := range Catalog {
+		if d := distance(string(f), string(name)); d < limit || (d == limit && name < best) {
+			best, limit = name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func checkDirName(values []string) error {
+	if filepath.Base(values[0]) != values[0] {
+		return fmt.Errorf("should be a dir name inside the --%s, not a path, got %q", UserDataDir, values[0])
+	}
+	return nil
+}
+
+func checkScreen(values []string) error {
+	parts := strings.Split(values[0], "x")
+	ok := len(parts) == 3
+	for _, v := range parts {
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			ok = false
+		}
+	}
+	if !ok {
+		return fmt.Errorf(`should be like "1280x720x24", got %q`, values[0])
+	}
+	return nil
+}
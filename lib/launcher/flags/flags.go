@@ -8,6 +8,7 @@ import "strings"
 type Flag string
 
 // TODO: we should automatically generate all the flags here.
+// For now the [Catalog] is curated by hand, it only describes the common flags.
 const (
 	// UserDataDir https://chromium.googlesource.com/chromium/src/+/master/docs/user_data_dir.md
 	UserDataDir Flag = "user-data-dir"
@@ -42,6 +43,9 @@ const (
 	// XVFB flag.
 	XVFB Flag = "rod-xvfb"
 
+	// XvfbScreen flag, the launcher starts its own Xvfb with the screen, such as "1280x720x24".
+	XvfbScreen Flag = "rod-xvfb-screen"
+
 	// ProfileDir flag.
 	ProfileDir = "profile-directory"
 
@@ -54,9 +58,21 @@ const (
 	// Bin is the browser executable file path. If it's empty, launcher will automatically search or download the bin.
 	Bin Flag = "rod-bin"
 
+	// RemoteDebuggingPipe flag, the browser will use the fd 3 and 4 for the remote debugging.
+	RemoteDebuggingPipe Flag = "remote-debugging-pipe"
+
+	// StrictFlags flag, if set the problems found by [Validate] will fail the launch.
+	StrictFlags Flag = "rod-strict-flags"
+
+	// UserDataDirTemplate flag, the dir to copy into the [UserDataDir] before the launch.
+	UserDataDirTemplate Flag = "rod-user-data-dir-template"
+
 	// KeepUserDataDir flag.
 	KeepUserDataDir Flag = "rod-keep-user-data-dir"
 
+	// ReattachGrace flag, the seconds to keep the remote browser alive after the client disconnects.
+	ReattachGrace Flag = "rod-reattach-grace"
+
 	// Arguments for the command. Such as
 	//     chrome-bin http://a.com http://b.com
 	// The "http://a.com" and "http://b.com" are the arguments.
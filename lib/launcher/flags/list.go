@@ -0,0 +1,163 @@
+package flags
+
+import "slices"
+
+// Catalog of the common flags, the flags of rod itself are prefixed with "rod-" and won't be passed to the browser.
+// It's curated by hand instead of being generated, the descriptions are from
+// https://peter.sh/experiments/chromium-command-line-switches .
+// The catalog can't cover all the flags of the browser, so an unknown flag is only a warning of [Validate].
+var Catalog = map[Flag]*Info{}
+
+func init() {
+	for _, i := range catalogList {
+		Catalog[i.Name] = i
+	}
+
+	// make the conflicts symmetric
+	for _, i := range catalogList {
+		for _, c := range i.Conflicts {
+			other := Catalog[c]
+			if !slices.Contains(other.Conflicts, i.Name) {
+				other.Conflicts = append(other.Conflicts, i.Name)
+			}
+		}
+	}
+}
+
+var catalogList = []*Info{
+	// rod
+	{Name: Bin, Type: TypeString, Description: "Path of the browser executable, it disables the auto download."},
+	{Name: Env, Type: TypeList, Description: "Env vars of the browser process."},
+	{Name: KeepUserDataDir, Type: TypeBool, Description: "Keep the user data dir after the remote browser exits."},
+	{Name: Leakless, Type: TypeBool, Description: "Kill the browser after the Go process exits."},
+	{Name: Preferences, Type: TypeString, Description: "User preferences json of the browser."},
+	{Name: ReattachGrace, Type: TypeInt, Description: "Seconds to keep the remote browser alive after the client disconnects."},
+	{Name: StrictFlags, Type: TypeBool, Description: "Fail the launch on the problems of the flags."},
+	{Name: UserDataDirTemplate, Type: TypeString, Description: "Dir to copy into the user data dir before the launch."},
+	{Name: WorkingDir, Type: TypeString, Description: "Working dir of the browser process."},
+	{Name: XVFB, Type: TypeList, Description: "Run the browser with xvfb-run and the args."},
+	{
+		Name: XvfbScreen, Type: TypeString, Check: checkScreen, Conflicts: []Flag{XVFB},
+		Description: "Start an Xvfb with the screen for the browser, such as \"1280x720x24\".",
+	},
+
+	// modes
+	{
+		Name: Headless, Type: TypeEnum, Values: []string{"new", "old"}, Conflicts: []Flag{App, "kiosk"},
+		Description: "Run in headless mode, i.e., without a UI or display server dependencies.",
+	},
+	{Name: App, Type: TypeString, Description: "Run the url in app mode, a window without the browser UI."},
+	{Name: "kiosk", Type: TypeBool, Description: "Start the browser in kiosk mode, a full screen window without the browser UI."},
+	{Name: "incognito", Type: TypeBool, Description: "Launch the browser in incognito mode."},
+	{Name: "start-maximized", Type: TypeBool, Description: "Start the browser maximized."},
+	{Name: "start-fullscreen", Type: TypeBool, Description: "Start the browser in full screen."},
+	{Name: "auto-open-devtools-for-tabs", Type: TypeBool, Description: "Open the devtools for each tab."},
+	{Name: "test-type", Type: TypeBool, Description: "Hide the warnings of the unsupported flags."},
+
+	// profile
+	{Name: UserDataDir, Type: TypeString, Description: "Directory where the browser stores the user profiles."},
+	{
+		Name: ProfileDir, Type: TypeString, Check: checkDirName,
+		Description: "Name of the profile dir inside the user data dir to use, such as \"Default\" or \"Profile 1\".",
+	},
+
+	// debugging
+	{
+		Name: RemoteDebuggingPort, Type: TypeInt, Conflicts: []Flag{RemoteDebuggingPipe},
+		Description: "Enable the remote debugging over websocket on the port, 0 means a random port.",
+	},
+	{
+		Name: RemoteDebuggingPipe, Type: TypeEnum, Values: []string{"cbor"},
+		Description: "Enable the remote debugging over the pipe fd 3 and 4.",
+	},
+	{Name: "remote-debugging-address", Type: TypeString, Description: "Address to listen for the remote debugging."},
+	{Name: "remote-allow-origins", Type: TypeList, Description: "Origins that are allowed to connect to the remote debugging."},
+	{Name: "enable-logging", Type: TypeEnum, Values: []string{"stderr"}, Description: "Enable the logging."},
+	{Name: "log-level", Type: TypeInt, Description: "Minimum log level, 0 is info, 1 is warning, 2 is error, 3 is fatal."},
+	{Name: "v", Type: TypeInt, Description: "Verbose log level."},
+	{Name: "js-flags", Type: TypeString, Description: "Flags passed to V8."},
+
+	// network
+	{
+		Name: ProxyServer, Type: TypeString, Conflicts: []Flag{"no-proxy-server", "proxy-pac-url"},
+		Description: "Proxy server to use, such as \"127.0.0.1:8080\" or \"socks5://127.0.0.1:1080\".",
+	},
+	{Name: "proxy-bypass-list", Type: TypeList, Description: "Hosts that bypass the proxy, such as \"<-loopback>\"."},
+	{Name: "proxy-pac-url", Type: TypeString, Conflicts: []Flag{"no-proxy-server"}, Description: "Url of the proxy pac file."},
+	{Name: "no-proxy-server", Type: TypeBool, Description: "Don't use any proxy."},
+	{Name: "host-resolver-rules", Type: TypeString, Description: "Rules to map the host names, such as \"MAP * 127.0.0.1\"."},
+	{Name: "ignore-certificate-errors", Type: TypeBool, Description: "Ignore all the certificate errors."},
+	{Name: "ignore-certificate-errors-spki-list", Type: TypeList, Description: "SPKI fingerprints of the certificates to trust."},
+	{Name: "disable-http2", Type: TypeBool, Description: "Disable HTTP/2."},
+	{Name: "user-agent", Type: TypeString, Description: "User agent to use."},
+	{Name: "lang", Type: TypeString, Description: "Locale of the UI, such as \"en-US\"."},
+
+	// window and rendering
+	{Name: WindowSize, Type: TypeSize, Description: "Initial window size, such as \"1280,720\"."},
+	{Name: WindowPosition, Type: TypeSize, Description: "Initial window position, such as \"0,0\"."},
+	{Name: "force-device-scale-factor", Type: TypeFloat, Description: "Device scale factor of the screen."},
+	{Name: "force-color-profile", Type: TypeString, Description: "Color profile to use, such as \"srgb\"."},
+	{Name: "hide-scrollbars", Type: TypeBool, Description: "Hide the scrollbars of the pages."},
+	{Name: "disable-gpu", Type: TypeBool, Description: "Disable the GPU hardware acceleration."},
+	{Name: "disable-software-rasterizer", Type: TypeBool, Description: "Disable the software rasterizer when the GPU is disabled."},
+	{Name: "use-gl", Type: TypeString, Description: "GL implementation to use, such as \"swiftshader\"."},
+	{Name: "use-angle", Type: TypeString, Description: "ANGLE backend to use, such as \"swiftshader\"."},
+	{Name: "font-render-hinting", Type: TypeString, Description: "Font hinting, such as \"none\"."},
+	{Name: "run-all-compositor-stages-before-draw", Type: TypeBool, Description: "Make the screenshots more consistent."},
+	{Name: "mute-audio", Type: TypeBool, Description: "Mute the audio."},
+	{Name: "autoplay-policy", Type: TypeString, Description: "Autoplay policy, such as \"no-user-gesture-required\"."},
+
+	// media
+	{Name: "use-fake-ui-for-media-stream", Type: TypeBool, Description: "Auto accept the permission prompts of the camera and microphone."},
+	{Name: "use-fake-device-for-media-stream", Type: TypeBool, Description: "Use the fake camera and microphone."},
+	{Name: "use-file-for-fake-video-capture", Type: TypeString, Description: "Y4M or MJPEG file to use as the fake camera."},
+	{Name: "use-file-for-fake-audio-capture", Type: TypeString, Description: "WAV file to use as the fake microphone."},
+
+	// extensions
+	{
+		Name: "load-extension", Type: TypeList, Conflicts: []Flag{"disable-extensions"},
+		Description: "Dirs of the unpacked extensions to load.",
+	},
+	{Name: "disable-extensions-except", Type: TypeList, Description: "Disable all the extensions except these dirs."},
+	{Name: "disable-extensions", Type: TypeBool, Description: "Disable all the extensions."},
+	{Name: "disable-component-extensions-with-background-pages", Type: TypeBool, Description: "Disable the component extensions with background pages."},
+
+	// features
+	{Name: "enable-features", Type: TypeList, Description: "Features to enable."},
+	{Name: "disable-features", Type: TypeList, Description: "Features to disable."},
+	{Name: "enable-blink-features", Type: TypeList, Description: "Blink features to enable."},
+	{Name: "disable-blink-features", Type: TypeList, Description: "Blink features to disable, such as \"AutomationControlled\"."},
+	{Name: "enable-automation", Type: TypeBool, Description: "Tell the browser that it's controlled by automation."},
+
+	// sandbox and process model
+	{Name: NoSandbox, Type: TypeBool, Description: "Disable the sandbox, required when running as root without the user namespaces."},
+	{Name: "disable-setuid-sandbox", Type: TypeBool, Description: "Disable the setuid sandbox."},
+	{Name: "no-zygote", Type: TypeBool, Description: "Don't use the zygote process to fork the child processes."},
+	{Name: "single-process", Type: TypeBool, Description: "Run the renderer and plugins in the browser process."},
+	{Name: "disable-dev-shm-usage", Type: TypeBool, Description: "Use the temp dir instead of /dev/shm, for the small /dev/shm in containers."},
+	{Name: "disable-site-isolation-trials", Type: TypeBool, Description: "Disable the site isolation trials."},
+	{Name: "disable-web-security", Type: TypeBool, Description: "Disable the same origin policy."},
+	{Name: "allow-running-insecure-content", Type: TypeBool, Description: "Allow the mixed content."},
+
+	// background work
+	{Name: "no-first-run", Type: TypeBool, Description: "Skip the first run tasks."},
+	{Name: "no-startup-window", Type: TypeBool, Description: "Don't open a window on startup."},
+	{Name: "no-default-browser-check", Type: TypeBool, Description: "Don't check whether the browser is the default one."},
+	{Name: "disable-background-networking", Type: TypeBool, Description: "Disable the background network requests."},
+	{Name: "disable-background-timer-throttling", Type: TypeBool, Description: "Disable throttling the timers of the background pages."},
+	{Name: "disable-backgrounding-occluded-windows", Type: TypeBool, Description: "Don't treat the occluded windows as background."},
+	{Name: "disable-renderer-backgrounding", Type: TypeBool, Description: "Don't lower the priority of the background renderers."},
+	{Name: "disable-breakpad", Type: TypeBool, Description: "Disable the crash reporting."},
+	{Name: "disable-client-side-phishing-detection", Type: TypeBool, Description: "Disable the client side phishing detection."},
+	{Name: "disable-component-update", Type: TypeBool, Description: "Disable updating the browser components."},
+	{Name: "disable-default-apps", Type: TypeBool, Description: "Disable installing the default apps."},
+	{Name: "disable-hang-monitor", Type: TypeBool, Description: "Disable the hang monitor dialogs."},
+	{Name: "disable-ipc-flooding-protection", Type: TypeBool, Description: "Disable the IPC flooding protection."},
+	{Name: "disable-popup-blocking", Type: TypeBool, Description: "Disable the popup blocking."},
+	{Name: "disable-prompt-on-repost", Type: TypeBool, Description: "Disable the prompt when reloading a page with POST data."},
+	{Name: "disable-sync", Type: TypeBool, Description: "Disable syncing with the Google account."},
+	{Name: "disable-infobars", Type: TypeBool, Description: "Hide the infobars."},
+	{Name: "metrics-recording-only", Type: TypeBool, Description: "Record the metrics but don't report them."},
+	{Name: "password-store", Type: TypeString, Description: "Password store to use on Linux, such as \"basic\"."},
+	{Name: "use-mock-keychain", Type: TypeBool, Description: "Use the mock keychain on macOS to avoid the permission prompts."},
+}
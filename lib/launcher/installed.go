@@ -0,0 +1,303 @@
+package launcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Installed browser in the [Browser.RootDir].
+type Installed struct {
+	// Dir of the browser
+	Dir string
+
+	// Bin is the path of the browser executable
+	Bin string
+
+	// Revision of the chromium build, it's 0 for the Chrome for Testing builds.
+	Revision int
+
+	// Version of the browser, such as "120.0.6099.109". It's empty if it can't be detected.
+	Version string
+
+	// HeadlessShell is true if it's a chrome-headless-shell build.
+	HeadlessShell bool
+
+	// Size of all the files in bytes
+	Size int64
+
+	// LastUse is the last time the browser is used by [Browser.Get].
+	// If it has never been used, it's the time when the browser is installed.
+	LastUse time.Time
+}
+
+// The file to track the last use of a browser, its modification time is the last use time.
+const lastUseStamp = ".last-use"
+
+var (
+	regChromium      = regexp.MustCompile(`^chromium-(\d+)$`)
+	regCfT           = regexp.MustCompile(`^(chrome|chrome-headless-shell)-(\d+\.\d+\.\d+\.\d+)$`)
+	regVersionOutput = regexp.MustCompile(`\d+\.\d+\.\d+\.\d+`)
+)
+
+// ListInstalled is a shortcut for [Browser.ListInstalled] of the [DefaultBrowserDir].
+func ListInstalled() ([]*Installed, error) {
+	return NewBrowser().ListInstalled()
+}
+
+// Remove is a shortcut for [Browser.Remove] of the [DefaultBrowserDir].
+func Remove(rev int) error {
+	return NewBrowser().Remove(rev)
+}
+
+// Prune is a shortcut for [Browser.Prune] of the [DefaultBrowserDir].
+func Prune(keep int) ([]*Installed, error) {
+	return NewBrowser().Prune(keep)
+}
+
+// ListInstalled returns the browsers downloaded to the [Browser.RootDir], the most recently used comes first.
+func (lc *Browser) ListInstalled() ([]*Installed, error) {
+	entries, err := os.ReadDir(lc.RootDir)
+	if os.IsNotExist(err) {
+		return []*Installed{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	list := []*Installed{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		b := *lc
+		b.resolved = nil
+
+		if m := regChromium.FindStringSubmatch(e.Name()); m != nil {
+			b.Revision, _ = strconv.Atoi(m[1])
+			b.Version = ""
+		} else if m := regCfT.FindStringSubmatch(e.Name()); m != nil {
+			b.Version = m[2]
+			b.HeadlessShell = m[1] == "chrome-headless-shell"
+		} else {
+			continue
+		}
+
+		i, err := b.installed()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, i)
+	}
+
+	sort.SliceStable(list, func(a, b int) bool {
+		return list[a].LastUse.After(list[b].LastUse)
+	})
+
+	return list, nil
+}
+
+func (lc *Browser) installed() (*Installed, error) {
+	i := &Installed{
+		Dir:           lc.Dir(),
+		Bin:           lc.BinPath(),
+		HeadlessShell: lc.HeadlessShell,
+	}
+
+	if lc.Version == "" {
+		i.Revision = lc.Revision
+		i.Version = installedVersion(i.Dir, i.Bin)
+	} else {
+		i.Version = lc.Version
+	}
+
+	err := filepath.WalkDir(i.Dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			i.Size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filepath.Join(i.Dir, lastUseStamp))
+	if err != nil {
+		info, err = os.Stat(i.Dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.LastUse = info.ModTime()
+
+	return i, nil
+}
+
+// Remove the downloaded chromium revision from the [Browser.RootDir].
+// It waits for the [Browser.LockPort] so that it won't remove a browser that is being downloaded.
+func (lc *Browser) Remove(rev int) error {
+	unlock, err := lc.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b := *lc
+	b.Revision = rev
+	b.Version = ""
+
+	dir := b.Dir()
+	_, err = os.Stat(dir)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// Prune removes the browsers in the [Browser.RootDir] except the most recently used keep ones,
+// and the temp files left by the interrupted installs. It returns the removed browsers.
+// It waits for the [Browser.LockPort] so that it won't remove a browser that is being downloaded.
+func (lc *Browser) Prune(keep int) ([]*Installed, error) {
+	unlock, err := lc.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	list, err := lc.ListInstalled()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []*Installed{}
+	for i, b := range list {
+		if i < keep {
+			continue
+		}
+
+		err = os.RemoveAll(b.Dir)
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, b)
+	}
+
+	tmps, err := filepath.Glob(filepath.Join(lc.RootDir, ".install-*"))
+	if err != nil {
+		return removed, err
+	}
+	for _, p := range tmps {
+		err = os.RemoveAll(p)
+		if err != nil {
+			return removed, err
+		}
+	}
+
+	return removed, nil
+}
+
+// DefaultLockTimeout is the default [Browser.LockTimeout].
+const DefaultLockTimeout = 5 * time.Minute
+
+// lock the [Browser.LockPort] to prevent other processes from installing or removing browsers at the same time.
+func (lc *Browser) lock() (unlock func(), err error) {
+	if lc.LockPort == 0 {
+		return func() {}, nil
+	}
+
+	timeout := lc.LockTimeout
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
+	}
+	ctx, cancel := context.WithTimeout(lc.Context, timeout)
+	defer cancel()
+
+	for {
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", lc.LockPort))
+		if err == nil {
+			return func() { _ = l.Close() }, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lc.Context.Err() == nil {
+				return nil, fmt.Errorf("failed to lock the port %d in %s, it may be used by another program: %w",
+					lc.LockPort, timeout, ctx.Err())
+			}
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// stamp the last use time of the browser.
+func (lc *Browser) stamp() error {
+	p := filepath.Join(lc.Dir(), lastUseStamp)
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return os.Chtimes(p, now, now)
+}
+
+// installedVersion detects the version of a chromium build.
+func installedVersion(dir, bin string) string {
+	// On Windows the chromium build has a sub dir named after its version
+	if runtime.GOOS == "windows" {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if e.IsDir() && regVersion.MatchString(e.Name()) {
+				return e.Name()
+			}
+		}
+		return ""
+	}
+
+	v, _ := binVersion(context.Background(), bin)
+	return v
+}
+
+const binVersionTimeout = 5 * time.Second
+
+// binVersion runs the browser executable with "--version" and parses the version from the output,
+// such as "Chromium 120.0.6099.109".
+func binVersion(ctx context.Context, bin string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, binVersionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, bin, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+
+	v := regVersionOutput.FindString(string(out))
+	if v == "" {
+		return "", fmt.Errorf("can't parse the version from the output: %s", strings.TrimSpace(string(out)))
+	}
+	return v, nil
+}
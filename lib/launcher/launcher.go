@@ -6,21 +6,28 @@ import (
 	"crypto"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/halicoming/rod/lib/defaults"
 	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/utils"
 )
 
 // DefaultUserDataDirPrefix ...
 var DefaultUserDataDirPrefix = filepath.Join(os.TempDir(), "rod", "user-data")
 
+// DefaultUserDataDirTemplatePrefix is the dir that the [Manager] allows the templates of [Launcher.UserDataDirFrom] from.
+// It's separated from the [DefaultUserDataDirPrefix] so that a client can't use the user data dir of another session as the template.
+var DefaultUserDataDirTemplatePrefix = filepath.Join(os.TempDir(), "rod", "user-data-template")
+
 // Launcher is a helper to launch browser binary smartly.
 type Launcher struct {
 	Flags map[flags.Flag][]string `json:"flags"`
@@ -32,11 +39,22 @@ type Launcher struct {
 
 	browser *Browser
 	parser  *URLParser
+	output  *ring
 	pid     int
 	exit    chan struct{}
+	bin     string
+	args    []string
+	state   *os.ProcessState
+	killed  int32
+	xvfb    *xvfb
+
+	managed       bool
+	serviceURL    string
+	managedHeader http.Header
+	sessionID     string
 
-	managed    bool
-	serviceURL string
+	// err of the [New], such as the failure to load the default profile, it's returned by [Launcher.Launch]
+	err error
 
 	isLaunched int32 // zero means not launched
 }
@@ -47,7 +65,24 @@ type Launcher struct {
 // UserDataDir will use OS tmp dir by default, this folder will usually be cleaned up by the OS after reboot.
 // It will auto download the browser binary according to the current platform,
 // check [Launcher.Bin] and [Launcher.Revision] for more info.
+// If the "profile" option of [defaults] is set, the profile will be applied, check [Load] for more info.
+// If the profile can't be loaded, the error will be returned by [Launcher.Launch].
 func New() *Launcher {
+	l := newLauncher()
+
+	if defaults.Profile != "" {
+		p, err := defaultProfile()
+		if err != nil {
+			l.err = err
+		} else {
+			l.Profile(p)
+		}
+	}
+
+	return l
+}
+
+func newLauncher() *Launcher {
 	dir := defaults.Dir
 	if dir == "" {
 		dir = filepath.Join(DefaultUserDataDirPrefix, utils.RandString(8))
@@ -115,6 +150,7 @@ func New() *Launcher {
 		exit:      make(chan struct{}),
 		browser:   NewBrowser(),
 		parser:    NewURLParser(),
+		output:    newRing(DefaultOutputSize),
 		logger:    io.Discard,
 	}
 }
@@ -137,6 +173,7 @@ func NewUserMode() *Launcher {
 		browser: NewBrowser(),
 		exit:    make(chan struct{}),
 		parser:  NewURLParser(),
+		output:  newRing(DefaultOutputSize),
 		logger:  io.Discard,
 	}
 }
@@ -219,6 +256,19 @@ func (l *Launcher) Revision(rev int) *Launcher {
 	return l
 }
 
+// Version of the Chrome for Testing build to auto download, such as "stable", "120", or "120.0.6099.109".
+// Check [Browser.Version] for details.
+func (l *Launcher) Version(v string) *Launcher {
+	l.browser.Version = v
+	return l
+}
+
+// HeadlessShell switch. Whether to auto download the chrome-headless-shell build of the [Launcher.Version].
+func (l *Launcher) HeadlessShell(enable bool) *Launcher {
+	l.browser.HeadlessShell = enable
+	return l
+}
+
 // Headless switch. Whether to run browser in headless mode. A mode without visible UI.
 func (l *Launcher) Headless(enable bool) *Launcher {
 	if enable {
@@ -248,6 +298,7 @@ func (l *Launcher) NoSandbox(enable bool) *Launcher {
 }
 
 // XVFB enables to run browser in by XVFB. Useful when you want to run headful mode on linux.
+// To let the launcher own the Xvfb process, use [Launcher.XvfbScreen] instead.
 func (l *Launcher) XVFB(args ...string) *Launcher {
 	return l.Set(flags.XVFB, args...)
 }
@@ -282,6 +333,22 @@ func (l *Launcher) Devtools(autoOpenForTabs bool) *Launcher {
 	return l.Delete("auto-open-devtools-for-tabs")
 }
 
+// FakeMedia makes the browser use the fake camera and microphone, so that getUserMedia works headlessly
+// with deterministic input. The videoY4M is the path of a Y4M or MJPEG file for the camera, check [utils.EncodeY4M],
+// the audioWAV is the path of a WAV file for the microphone, they are looped. If a path is empty, the browser
+// generates a test pattern or a beep for it instead. The paths are on the host of the browser.
+// Use the Page.GrantUserMedia of rod to skip the permission prompts.
+func (l *Launcher) FakeMedia(videoY4M, audioWAV string) *Launcher {
+	l.Set("use-fake-device-for-media-stream")
+	if videoY4M != "" {
+		l.Set("use-file-for-fake-video-capture", videoY4M)
+	}
+	if audioWAV != "" {
+		l.Set("use-file-for-fake-audio-capture", audioWAV)
+	}
+	return l
+}
+
 // IgnoreCerts configure the Chrome's ignore-certificate-errors-spki-list argument with the public keys.
 func (l *Launcher) IgnoreCerts(pks []crypto.PublicKey) error {
 	spkis := make([]string, 0, len(pks))
@@ -363,8 +430,41 @@ func (l *Launcher) StartURL(u string) *Launcher {
 	return l.Set("", u)
 }
 
+// StrictFlags switch. If enabled, the problems found by [Launcher.ValidateFlags] will fail the [Launcher.Launch],
+// such as the unknown flags, the invalid values, and the conflicts. If disabled, they are only warnings
+// written to the [Launcher.Logger].
+func (l *Launcher) StrictFlags(enable bool) *Launcher {
+	if enable {
+		return l.Set(flags.StrictFlags)
+	}
+	return l.Delete(flags.StrictFlags)
+}
+
+// ValidateFlags against the [flags.Catalog]. The returned error is [flags.Problems] if it's not nil,
+// use [flags.Problems.Errors] to ignore the warnings, such as the unknown flags.
+func (l *Launcher) ValidateFlags() error {
+	list := flags.Validate(l.Flags)
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+// strictFlags returns the problems of the flags if the [Launcher.StrictFlags] is enabled.
+func (l *Launcher) strictFlags() error {
+	if !l.Has(flags.StrictFlags) {
+		return nil
+	}
+	return l.ValidateFlags()
+}
+
 // FormatArgs returns the formatted arg list for cli.
+// The problems of the flags found by [flags.Validate] will be written to the [Launcher.Logger].
 func (l *Launcher) FormatArgs() []string {
+	for _, p := range flags.Validate(l.Flags) {
+		_, _ = fmt.Fprintf(l.logger, "[launcher] %s\n", p.Error())
+	}
+
 	execArgs := []string{}
 	for k, v := range l.Flags {
 		if k == flags.Arguments {
@@ -415,18 +515,33 @@ func (l *Launcher) MustLaunch() string {
 // If you want to reuse sessions, such as cookies, set the [Launcher.UserDataDir] to the same location.
 //
 // Please note launcher can only be used once.
-func (l *Launcher) Launch() (string, error) {
+func (l *Launcher) Launch() (u string, err error) {
 	if l.hasLaunched() {
 		return "", ErrAlreadyLaunched
 	}
 
 	defer l.ctxCancel()
+	defer observeLaunch(time.Now(), &err)
+
+	if l.err != nil {
+		return "", l.err
+	}
+
+	err = l.strictFlags()
+	if err != nil {
+		return "", err
+	}
 
 	bin, err := l.getBin()
 	if err != nil {
 		return "", err
 	}
 
+	err = l.setupUserDataDir()
+	if err != nil {
+		return "", err
+	}
+
 	l.setupUserPreferences()
 
 	var cmd *exec.Cmd
@@ -434,23 +549,38 @@ func (l *Launcher) Launch() (string, error) {
 	args := l.FormatArgs()
 
 	port := l.Get(flags.RemoteDebuggingPort)
-	u, err := ResolveURL(port)
+	u, err = ResolveURL(port)
 	if err == nil {
 		return u, nil
 	}
+	if l.Has(flags.XvfbScreen) {
+		l.xvfb, err = l.startXvfb()
+		if err != nil {
+			return "", err
+		}
+	}
+
 	cmd = exec.Command(bin, args...)
 
+	l.bin = bin
+	l.args = args
 	l.setupCmd(cmd)
 
 	err = cmd.Start()
 	if err != nil {
-		return "", err
+		l.stopXvfb()
+		return "", l.launchErr(err)
 	}
 
 	l.pid = cmd.Process.Pid
 
+	metrics.BrowsersAlive.Inc("")
+
 	go func() {
 		_ = cmd.Wait()
+		l.state = cmd.ProcessState
+		metrics.BrowsersAlive.Dec("")
+		l.stopXvfb()
 		close(l.exit)
 	}()
 
@@ -463,6 +593,16 @@ func (l *Launcher) Launch() (string, error) {
 	return ResolveURL(u)
 }
 
+func observeLaunch(start time.Time, err *error) {
+	metrics.BrowserLaunchDuration.Since("", start)
+
+	if *err == nil {
+		metrics.BrowserLaunches.Inc("ok")
+	} else {
+		metrics.BrowserLaunches.Inc("error")
+	}
+}
+
 func (l *Launcher) hasLaunched() bool {
 	return !atomic.CompareAndSwapInt32(&l.isLaunched, 0, 1)
 }
@@ -493,11 +633,26 @@ func (l *Launcher) setupCmd(cmd *exec.Cmd) {
 
 	dir := l.Get(flags.WorkingDir)
 	env, _ := l.GetFlags(flags.Env)
+	if l.xvfb != nil {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(env[:len(env):len(env)], "DISPLAY="+l.xvfb.display)
+	}
 	cmd.Dir = dir
 	cmd.Env = env
 
-	cmd.Stdout = io.MultiWriter(l.logger, l.parser)
-	cmd.Stderr = io.MultiWriter(l.logger, l.parser)
+	cmd.Stdout = io.MultiWriter(l.logger, l.parser, l.output)
+	cmd.Stderr = io.MultiWriter(l.logger, l.parser, l.output)
+
+	// the orphan children of a crashed browser may keep the output pipes open, don't wait for them forever
+	cmd.WaitDelay = time.Second
+}
+
+func (l *Launcher) stopXvfb() {
+	if l.xvfb != nil {
+		l.xvfb.stop()
+	}
 }
 
 func (l *Launcher) getBin() (string, error) {
@@ -515,11 +670,63 @@ func (l *Launcher) getURL() (u string, err error) {
 		err = l.ctx.Err()
 	case u = <-l.parser.URL:
 	case <-l.exit:
-		err = l.parser.Err()
+		err = l.launchErr(nil)
 	}
 	return
 }
 
+func (l *Launcher) launchErr(err error) *LaunchError {
+	out := l.Output()
+
+	e := &LaunchError{
+		Bin:     l.bin,
+		Args:    l.args,
+		Failure: DetectFailure(out),
+		Output:  out,
+		Err:     err,
+	}
+
+	select {
+	case <-l.exit:
+		e.Exit = l.state
+	default:
+	}
+
+	return e
+}
+
+// Output returns the recent stdout and stderr of the browser, at most [DefaultOutputSize] bytes.
+func (l *Launcher) Output() string {
+	return l.output.String()
+}
+
+// ExitStatus returns the exit status of the browser process, it returns nil if the process hasn't exited.
+func (l *Launcher) ExitStatus() *ExitStatus {
+	select {
+	case <-l.exit:
+	default:
+		return nil
+	}
+
+	if l.state == nil {
+		return &ExitStatus{Code: -1}
+	}
+
+	return &ExitStatus{Code: l.state.ExitCode(), Signal: exitSignal(l.state)}
+}
+
+// Wait until the browser process exits. It returns nil if the browser exits normally or is killed by [Launcher.Kill],
+// otherwise it returns a [*LaunchError] with the diagnostics of the browser output.
+func (l *Launcher) Wait() error {
+	<-l.exit
+
+	if atomic.LoadInt32(&l.killed) == 1 || (l.state != nil && l.state.Success()) {
+		return nil
+	}
+
+	return l.launchErr(nil)
+}
+
 // PID returns the browser process pid.
 func (l *Launcher) PID() int {
 	return l.pid
@@ -535,14 +742,19 @@ func (l *Launcher) Kill() {
 		return
 	}
 
+	atomic.StoreInt32(&l.killed, 1)
+
 	killGroup(l.PID())
 	p, err := os.FindProcess(l.PID())
 	if err == nil {
 		_ = p.Kill()
 	}
+
+	l.stopXvfb()
 }
 
 // Cleanup wait until the Browser exits and remove [flags.UserDataDir].
+// The Xvfb of [Launcher.XvfbScreen] is already stopped when the browser exits.
 func (l *Launcher) Cleanup() {
 	<-l.exit
 
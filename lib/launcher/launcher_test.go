@@ -1,26 +1,45 @@
 package launcher_test
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"flag"
+	"fmt"
+	"image/color"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/mux"
 	"github.com/halicoming/rod/lib/defaults"
 	"github.com/halicoming/rod/lib/launcher"
 	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/utils"
 	"github.com/ysmood/got"
+	"github.com/ysmood/gson"
 )
 
 var setup = got.Setup(nil)
@@ -189,6 +208,107 @@ func TestLaunchErr(t *testing.T) {
 	}
 }
 
+func TestValidateFlags(t *testing.T) {
+	g := setup(t)
+
+	g.Nil(launcher.New().ValidateFlags())
+	g.Nil(launcher.NewUserMode().ValidateFlags())
+	g.Nil(launcher.New().HeadlessNew(true).WindowSize(1280, 720).ProfileDir("Profile 1").ValidateFlags())
+
+	info, has := flags.Lookup("--headless")
+	g.True(has)
+	g.Eq(info.Type, flags.TypeEnum)
+	g.Has(info.Conflicts, flags.App)
+
+	app, _ := flags.Lookup(flags.App)
+	g.Has(app.Conflicts, flags.Headless)
+
+	list := flags.Validate(map[flags.Flag][]string{
+		"no-sandbx":                     nil,
+		flags.Headless:                  {"x"},
+		flags.App:                       {"http://a.com"},
+		flags.ProfileDir:                {"a/b"},
+		flags.RemoteDebuggingPort:       {"a"},
+		flags.RemoteDebuggingPipe:       nil,
+		flags.WindowSize:                {"1280"},
+		"some-unknown-flag-of-browsers": nil,
+	})
+	g.Eq(list.Error(), `flag --app: conflicts with --headless; `+
+		`flag --headless: should be one of ["new" "old"], got "x"; `+
+		`flag --no-sandbx: unknown flag, did you mean --no-sandbox?; `+
+		`flag --profile-directory: should be a dir name inside the --user-data-dir, not a path, got "a/b"; `+
+		`flag --remote-debugging-pipe: conflicts with --remote-debugging-port; `+
+		`flag --remote-debugging-port: should be an integer, got "a"; `+
+		`flag --some-unknown-flag-of-browsers: unknown flag; `+
+		`flag --window-size: should be like "1280,720", got "1280"`)
+	g.Len(list.Errors(), 6)
+
+	log := bytes.NewBuffer(nil)
+	l := launcher.New().Set("no-sandbx").Logger(log)
+	g.Eq(l.ValidateFlags().Error(), "flag --no-sandbx: unknown flag, did you mean --no-sandbox?")
+	g.Has(l.FormatArgs(), "--no-sandbx")
+	g.Eq(log.String(), "[launcher] flag --no-sandbx: unknown flag, did you mean --no-sandbox?\n")
+
+	_, err := l.StrictFlags(true).Launch()
+	_, ok := err.(flags.Problems) //nolint: errorlint
+	g.True(ok)
+	g.Eq(err.Error(), "flag --no-sandbx: unknown flag, did you mean --no-sandbox?")
+
+	_, err = launcher.New().Headless(true).Set(flags.App, "http://a.com").StrictFlags(true).Launch()
+	g.Eq(err.Error(), "flag --app: conflicts with --headless")
+
+	// without the strict mode, the problems are only warnings, such as the kiosk on the default headless launcher
+	bin := newFakeBrowser(g)
+	log.Reset()
+	l = launcher.New().Bin(bin).Set("kiosk").Logger(log)
+	l.MustLaunch()
+	l.Kill()
+	g.Has(log.String(), "[launcher] flag --headless: conflicts with --kiosk")
+}
+
+func TestUserDataDirFrom(t *testing.T) {
+	g := setup(t)
+
+	tpl := filepath.Join(t.TempDir(), "tpl")
+	g.WriteFile(filepath.Join(tpl, "Default", "Cookies"), "cookies")
+	g.WriteFile(filepath.Join(tpl, "Default", "Preferences"), "{}")
+	g.E(os.Symlink("host-123", filepath.Join(tpl, "SingletonLock")))
+
+	l := launcher.New().UserDataDir("").UserDataDirFrom(tpl).
+		Preferences(`{"a":1}`).Bin(filepath.Join(t.TempDir(), "not-exists"))
+	dir := l.Get(flags.UserDataDir)
+	g.Has(dir, launcher.DefaultUserDataDirPrefix)
+
+	_, err := l.Launch()
+	g.Err(err)
+	g.Eq(g.Read(filepath.Join(dir, "Default", "Cookies")).String(), "cookies")
+	g.Eq(g.Read(filepath.Join(dir, "Default", "Preferences")).String(), `{"a":1}`)
+	g.False(g.PathExists(filepath.Join(dir, "SingletonLock")))
+	g.Eq(g.Read(filepath.Join(tpl, "Default", "Preferences")).String(), "{}")
+
+	snapshot := filepath.Join(t.TempDir(), "snapshot")
+	l.MustSnapshotUserDataDir(snapshot)
+	g.Eq(g.Read(filepath.Join(snapshot, "Default", "Cookies")).String(), "cookies")
+	g.Eq(l.SnapshotUserDataDir(snapshot).Error(), "[launcher] snapshot dest already exists: "+snapshot)
+	g.Eq(launcher.New().UserDataDir("").SnapshotUserDataDir(snapshot).Error(), "[launcher] no user data dir to snapshot")
+
+	g.E(os.RemoveAll(dir))
+
+	{ // the template is only copied into an empty dir
+		dir := t.TempDir()
+		g.WriteFile(filepath.Join(dir, "a"), "a")
+		l := launcher.New().UserDataDir(dir).UserDataDirFrom(tpl).Bin(filepath.Join(dir, "not-exists"))
+		_, _ = l.Launch()
+		g.False(g.PathExists(filepath.Join(dir, "Default")))
+		g.False(l.UserDataDirFrom("").Has(flags.UserDataDirTemplate))
+	}
+
+	_, err = launcher.New().UserDataDirFrom(filepath.Join(tpl, "not-exists")).Bin("not-exists").Launch()
+	g.Has(err.Error(), "[launcher] invalid user data dir template")
+	_, err = launcher.New().UserDataDirFrom(filepath.Join(tpl, "Default", "Cookies")).Bin("not-exists").Launch()
+	g.Has(err.Error(), "[launcher] user data dir template is not a dir")
+}
+
 var testProfileDir = flag.Bool("test-profile-dir", false, "set it to test profile dir")
 
 func TestProfileDir(t *testing.T) {
@@ -312,6 +432,685 @@ func TestBrowserDownloadErr(t *testing.T) {
 	g.Err(b.Download())
 }
 
+func TestDownloadResume(t *testing.T) {
+	g := setup(t)
+
+	buf := bytes.NewBuffer(nil)
+	z := zip.NewWriter(buf)
+	f, _ := z.Create("chrome-linux/chrome")
+	_, _ = f.Write([]byte(g.RandStr(500 * 1024)))
+	_ = z.Close()
+	data := buf.Bytes()
+
+	sum := sha256.Sum256(data)
+
+	ranges := []string{}
+	s := g.Serve()
+	s.Mux.HandleFunc("/chrome.zip", func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+
+		if len(ranges) == 1 {
+			// the connection breaks in the middle of the download
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			_, _ = w.Write(data[:len(data)/2])
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+
+		http.ServeContent(w, r, "chrome.zip", time.Time{}, bytes.NewReader(data))
+	})
+
+	manifest, err := launcher.ParseManifest(strings.NewReader(
+		"# comment\n\n" + hex.EncodeToString(sum[:]) + " *" + s.URL("/chrome.zip") + "\n"))
+	g.E(err)
+
+	progress := []launcher.DownloadProgress{}
+
+	b := launcher.NewBrowser()
+	b.Revision = 1
+	b.RootDir = t.TempDir()
+	b.Logger = utils.LoggerQuiet
+	b.Manifest = manifest
+	b.Progress = func(p launcher.DownloadProgress) { progress = append(progress, p) }
+	b.Hosts = []launcher.Host{func(_ int) string { return s.URL("/chrome.zip") }}
+
+	err = b.Download()
+	g.Is(err, &launcher.DownloadError{})
+	g.Has(err.Error(), s.URL("/chrome.zip")+": ")
+	g.NotNil(os.Stat(b.Dir()))
+
+	progress = nil
+	g.E(b.Download())
+	g.Eq(ranges[1], "bytes="+strconv.Itoa(len(data)/2)+"-")
+	g.Eq(progress[0].Total, int64(len(data)))
+	g.Gt(progress[0].Downloaded, int64(len(data)/2))
+	g.Eq(progress[len(progress)-1].Downloaded, int64(len(data)))
+
+	g.True(g.PathExists(filepath.Join(b.Dir(), "chrome")))
+
+	list, err := os.ReadDir(b.RootDir)
+	g.E(err)
+	g.Len(list, 1) // no partial archive or temp dir left
+}
+
+func TestDownloadChecksumErr(t *testing.T) {
+	g := setup(t)
+
+	s := g.Serve()
+	s.Route("/a.zip", ".zip", "a")
+	s.Route("/b.zip", ".zip", "b")
+
+	b := launcher.NewBrowser()
+	b.Revision = 1
+	b.RootDir = t.TempDir()
+	b.Logger = utils.LoggerQuiet
+	b.Manifest = launcher.Manifest{s.URL("/a.zip"): "00"}
+	b.Hosts = []launcher.Host{
+		func(_ int) string { return s.URL("/a.zip") },
+		func(_ int) string { return s.URL("/b.zip") },
+	}
+
+	err := b.Download()
+	g.Is(err, &launcher.ChecksumError{})
+
+	var e *launcher.DownloadError
+	g.True(errors.As(err, &e))
+	g.Len(e.Hosts, 2)
+
+	_, err = launcher.ParseManifest(strings.NewReader("00 a"))
+	g.Eq(err.Error(), `invalid SHA-256 checksum on manifest line 1: "00"`)
+
+	_, err = launcher.ParseManifest(strings.NewReader("a"))
+	g.Eq(err.Error(), `invalid manifest line 1: "a"`)
+}
+
+func TestBrowserVersion(t *testing.T) {
+	g := setup(t)
+
+	buf := bytes.NewBuffer(nil)
+	z := zip.NewWriter(buf)
+	f, _ := z.Create("chrome-headless-shell/chrome-headless-shell")
+	_, _ = f.Write([]byte("bin"))
+	_ = z.Close()
+
+	s := g.Serve()
+	s.Route("/archive.zip", ".zip", buf.Bytes())
+
+	version := func(v string, builds ...string) map[string]interface{} {
+		downloads := map[string]interface{}{}
+		for _, b := range builds {
+			list := []map[string]string{}
+			for _, p := range []string{"linux64", "mac-arm64", "mac-x64", "win32", "win64"} {
+				list = append(list, map[string]string{"platform": p, "url": s.URL("/archive.zip")})
+			}
+			downloads[b] = list
+		}
+		return map[string]interface{}{"version": v, "downloads": downloads}
+	}
+
+	s.Route("/cft/known-good-versions-with-downloads.json", ".json", map[string]interface{}{
+		"versions": []interface{}{
+			version("119.0.6045.105", "chrome", "chrome-headless-shell"),
+			version("120.0.6099.9", "chrome", "chrome-headless-shell"),
+			version("120.0.6099.109", "chrome", "chrome-headless-shell"),
+			version("120.0.6099.110", "chrome"),
+		},
+	})
+	s.Route("/cft/last-known-good-versions-with-downloads.json", ".json", map[string]interface{}{
+		"channels": map[string]interface{}{
+			"Stable": version("119.0.6045.105", "chrome"),
+		},
+	})
+
+	b := launcher.NewBrowser()
+	b.RootDir = t.TempDir()
+	b.Logger = utils.LoggerQuiet
+	b.MetadataURL = s.URL("/cft/")
+
+	b.Version = "stable"
+	g.E(b.Resolve())
+	g.Eq(filepath.Base(b.Dir()), "chrome-119.0.6045.105")
+
+	b.Version = "120"
+	g.E(b.Resolve())
+	g.Eq(filepath.Base(b.Dir()), "chrome-120.0.6099.110")
+
+	b.HeadlessShell = true
+	g.E(b.Download())
+	g.Eq(filepath.Base(b.Dir()), "chrome-headless-shell-120.0.6099.109")
+	g.True(g.PathExists(filepath.Join(b.Dir(), "chrome-headless-shell")))
+	g.Has(b.BinPath(), "chrome-headless-shell")
+
+	b.Version = "121"
+	g.Eq(b.Resolve().Error(), "browser version 121 not found in the metadata")
+
+	b.Version = "latest"
+	g.Has(b.Resolve().Error(), "invalid browser version")
+
+	b.Version = "beta"
+	g.Eq(b.Resolve().Error(), "channel Beta not found in the metadata")
+
+	b.MetadataURL = s.URL("/not-found")
+	g.Has(b.Resolve().Error(), "404 Not Found")
+}
+
+func TestInstalled(t *testing.T) {
+	g := setup(t)
+
+	buf := bytes.NewBuffer(nil)
+	z := zip.NewWriter(buf)
+	f, _ := z.Create("chrome-linux/chrome")
+	_, _ = f.Write([]byte(g.RandStr(1024)))
+	_ = z.Close()
+
+	s := g.Serve()
+	s.Route("/chrome.zip", ".zip", buf.Bytes())
+
+	b := launcher.NewBrowser()
+	b.RootDir = t.TempDir()
+	b.Logger = utils.LoggerQuiet
+	b.LockPort = 0
+	b.Hosts = []launcher.Host{func(_ int) string { return s.URL("/chrome.zip") }}
+
+	list, err := b.ListInstalled()
+	g.E(err)
+	g.Len(list, 0)
+
+	mkdir := func(name string, lastUse time.Time) {
+		p := filepath.Join(b.RootDir, name, ".last-use")
+		g.WriteFile(p, "")
+		g.E(os.Chtimes(p, lastUse, lastUse))
+	}
+	mkdir("chrome-120.0.6099.109", time.Now().Add(-time.Hour))
+	mkdir("chrome-headless-shell-121.0.6167.85", time.Now().Add(-2*time.Hour))
+	mkdir("other", time.Now())
+	g.WriteFile(filepath.Join(b.RootDir, ".install-123", "a"), "")
+
+	b.Revision = 1
+	b.MustGet()
+	b.Revision = 2
+	b.MustGet()
+
+	list, err = b.ListInstalled()
+	g.E(err)
+	g.Len(list, 4)
+	g.Eq(list[0].Revision, 2)
+	g.Eq(list[0].Size, int64(1024))
+	g.Eq(list[0].Bin, b.BinPath())
+	g.Eq(list[2].Version, "120.0.6099.109")
+	g.True(list[3].HeadlessShell)
+
+	g.E(b.Remove(1))
+	g.Err(b.Remove(1))
+
+	removed, err := b.Prune(1)
+	g.E(err)
+	g.Len(removed, 2)
+	g.Eq(filepath.Base(removed[0].Dir), "chrome-120.0.6099.109")
+
+	list, err = b.ListInstalled()
+	g.E(err)
+	g.Len(list, 1)
+	g.Eq(list[0].Revision, 2)
+	g.True(g.PathExists(filepath.Join(b.RootDir, "other")))
+	g.False(g.PathExists(filepath.Join(b.RootDir, ".install-123")))
+}
+
+func TestBrowserLock(t *testing.T) {
+	g := setup(t)
+
+	if runtime.GOOS == "windows" {
+		g.Skip("the fake browsers are shell scripts")
+	}
+
+	// an unrelated program that uses the lock port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	g.E(err)
+	defer func() { _ = l.Close() }()
+
+	logs := bytes.NewBuffer(nil)
+	b := launcher.NewBrowser()
+	b.RootDir = t.TempDir()
+	b.Logger = log.New(logs, "", 0)
+	b.LockPort = l.Addr().(*net.TCPAddr).Port
+	b.LockTimeout = 300 * time.Millisecond
+	b.Revision = 1
+
+	g.WriteFile(b.BinPath(), "#!/bin/sh\necho '<html><head></head><body></body></html>'\n")
+	g.E(os.Chmod(b.BinPath(), 0o755))
+
+	// the stamp can't be written
+	g.E(os.MkdirAll(filepath.Join(b.Dir(), ".last-use"), 0o755))
+
+	// a valid browser doesn't need the lock
+	g.Eq(b.MustGet(), b.BinPath())
+	g.Has(logs.String(), "failed to update the last use time")
+
+	err = b.Remove(1)
+	g.Is(err, context.DeadlineExceeded)
+	g.Has(err.Error(), "it may be used by another program")
+}
+
+func TestBundle(t *testing.T) {
+	g := setup(t)
+
+	b := launcher.NewBrowser()
+	b.RootDir = t.TempDir()
+	b.Logger = utils.LoggerQuiet
+	b.LockPort = 0
+	b.Revision = 1
+
+	g.Err(b.Export(io.Discard))
+
+	g.WriteFile(b.BinPath(), "bin")
+	g.E(os.Chmod(b.BinPath(), 0o755))
+	g.WriteFile(filepath.Join(b.Dir(), "a", "b.txt"), "b")
+	g.E(os.Symlink(filepath.FromSlash("a/b.txt"), filepath.Join(b.Dir(), "link")))
+	g.WriteFile(filepath.Join(b.Dir(), ".last-use"), "")
+
+	bundle := bytes.NewBuffer(nil)
+	g.E(b.Export(bundle))
+
+	other := launcher.NewBrowser()
+	other.RootDir = t.TempDir()
+	other.LockPort = 0
+
+	i, err := other.Import(bytes.NewReader(bundle.Bytes()))
+	g.E(err)
+	g.Eq(i.Revision, 1)
+	g.Eq(i.Dir, filepath.Join(other.RootDir, "chromium-1"))
+	g.Eq(i.Size, int64(4))
+
+	info, err := os.Stat(i.Bin)
+	g.E(err)
+	g.Eq(info.Mode().Perm(), os.FileMode(0o755))
+	g.Eq(g.Read(filepath.Join(i.Dir, "link")).String(), "b")
+	g.False(g.PathExists(filepath.Join(i.Dir, ".last-use")))
+
+	list, err := other.ListInstalled()
+	g.E(err)
+	g.Len(list, 1)
+
+	bad := func(manifest string, entries ...string) []byte {
+		buf := bytes.NewBuffer(nil)
+		gz := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gz)
+		if manifest != "" {
+			g.E(tw.WriteHeader(&tar.Header{Name: "rod-bundle.json", Mode: 0o644, Size: int64(len(manifest))}))
+			_, _ = tw.Write([]byte(manifest))
+		}
+		for _, e := range entries {
+			// the entry "a -> b" is a symlink
+			name, link, isLink := strings.Cut(e, " -> ")
+			if isLink {
+				g.E(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: link, Mode: 0o777}))
+				continue
+			}
+			g.E(tw.WriteHeader(&tar.Header{Name: e, Mode: 0o644}))
+		}
+		g.E(tw.Close())
+		g.E(gz.Close())
+		return buf.Bytes()
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+
+	for _, c := range []struct {
+		bundle []byte
+		err    string
+	}{
+		{bad("", "a"), "the first entry should be rod-bundle.json"},
+		{bad(`{"name":"../a","platform":"` + platform + `"}`), `invalid name "../a"`},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"plan9/386"}`), "the browser is built for plan9/386"},
+		{bad(`{"name":"chromium-2","revision":1,"platform":"` + platform + `"}`), "doesn't match"},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"`+platform+`"}`, "browser/../a"), `invalid path`},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"`+platform+`"}`, "browser/a -> ../.."), `invalid symlink`},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"`+platform+`"}`,
+			"browser/a/b -> ..", "browser/a/b/c -> ..", "browser/a/b/c/x"), `the path of "a/b/c" has a symlink`},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"`+platform+`"}`,
+			"browser/a -> b", "browser/a"), `the path of "a" has a symlink`},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"`+platform+`","files":{"a":"00"}}`, "browser/a"), "a: SHA-256"},
+		{bad(`{"name":"chromium-1","revision":1,"platform":"` + platform + `","files":{"a":"00"}}`), "expected 1 files, got 0"},
+	} {
+		_, err := other.Import(bytes.NewReader(c.bundle))
+		g.Is(err, launcher.ErrInvalidBundle)
+		g.Has(err.Error(), c.err)
+	}
+
+	// the installed one is untouched by the failed imports
+	g.Eq(g.Read(i.Bin).String(), "bin")
+}
+
+func TestLaunchError(t *testing.T) {
+	g := setup(t)
+
+	if runtime.GOOS == "windows" {
+		g.Skip("the fake browsers are shell scripts")
+	}
+
+	fake := func(script string) string {
+		p := filepath.Join(t.TempDir(), "chrome")
+		g.E(os.WriteFile(p, []byte("#!/bin/sh\n"+script+"\n"), 0o755))
+		return p
+	}
+
+	bin := fake("echo 'chrome: error while loading shared libraries: libnss3.so: cannot open shared object file' >&2; exit 127")
+	l := launcher.New().Bin(bin)
+	_, err := l.Launch()
+	var e *launcher.LaunchError
+	g.True(errors.As(err, &e))
+	g.Eq(e.Bin, bin)
+	g.Eq(e.Failure, launcher.FailureMissingLibrary)
+	g.Has(e.Output, "libnss3.so")
+	g.Eq(e.Exit.ExitCode(), 127)
+	g.Has(err.Error(), "the shared libraries of the browser are missing")
+	g.Has(err.Error(), "(exit status 127)")
+	g.Eq(l.ExitStatus(), &launcher.ExitStatus{Code: 127})
+	g.Eq(l.Output(), e.Output)
+
+	l = launcher.New().Bin(fake("echo 'No usable sandbox!'; kill -SEGV $$"))
+	_, err = l.Launch()
+	g.Is(err, &launcher.LaunchError{})
+	g.Eq(err.(*launcher.LaunchError).Failure, launcher.FailureSandbox)
+	g.Eq(l.ExitStatus(), &launcher.ExitStatus{Code: -1, Signal: "segmentation fault"})
+
+	_, err = launcher.New().Bin(filepath.Join(t.TempDir(), "not-exists")).Launch()
+	g.True(errors.As(err, &e))
+	g.Nil(e.Exit)
+	g.True(errors.Is(err, os.ErrNotExist))
+
+	// the browser crashes after launched
+	s := g.Serve()
+	s.Route("/json/version", ".json", `{"webSocketDebuggerUrl": "ws://a/devtools/browser/id"}`)
+	read := filepath.Join(t.TempDir(), "crash")
+	l = launcher.New().Bin(fake(fmt.Sprintf(
+		"echo 'DevTools listening on ws://%s/devtools/browser/id'; while [ ! -f %s ]; do sleep 0.01; done; "+
+			"echo 'Received signal 11 SEGV_MAPERR 000000000000' >&2; exit 1",
+		s.HostURL.Host, read,
+	)))
+	u, err := l.Launch()
+	g.E(err)
+	g.Has(u, "/devtools/browser/id")
+	g.Nil(l.ExitStatus())
+	g.E(os.WriteFile(read, nil, 0o644))
+	err = l.Wait()
+	g.True(errors.As(err, &e))
+	g.Eq(e.Failure, launcher.FailureCrash)
+	g.Eq(l.ExitStatus(), &launcher.ExitStatus{Code: 1})
+
+	// killed by the launcher isn't an error
+	l = launcher.New().Bin(fake(fmt.Sprintf("echo 'DevTools listening on ws://%s/devtools/browser/id'; sleep 10", s.HostURL.Host)))
+	l.MustLaunch()
+	l.Kill()
+	g.E(l.Wait())
+	g.Eq(l.ExitStatus().Signal, "killed")
+}
+
+func TestSupervisorStopBeforeStart(t *testing.T) {
+	g := setup(t)
+
+	s := launcher.NewSupervisor(launcher.New())
+	s.Stop()
+	s.Stop()
+	<-s.Done()
+	g.Is(s.Err(), context.Canceled)
+
+	_, err := s.Start()
+	g.Eq(err, launcher.ErrAlreadyLaunched)
+}
+
+func TestSupervisor(t *testing.T) {
+	g := setup(t)
+
+	if runtime.GOOS == "windows" {
+		g.Skip("the fake browsers are shell scripts")
+	}
+
+	// a fake browser endpoint that responds an empty result to every call
+	newCDP := func() (string, chan string) {
+		methods := make(chan string, 10)
+		s := g.Serve()
+		s.Route("/json/version", ".json", `{"webSocketDebuggerUrl": "ws://a/devtools/browser/id"}`)
+		s.Mux.HandleFunc("/devtools/browser/id", func(w http.ResponseWriter, r *http.Request) {
+			ws, err := mux.Upgrade(w, r)
+			g.E(err)
+			for {
+				data, err := ws.Read()
+				if err != nil {
+					return
+				}
+				req := gson.New(data)
+				methods <- req.Get("method").Str()
+				g.E(ws.Send([]byte(fmt.Sprintf(`{"id":%d,"result":{}}`, req.Get("id").Int()))))
+				if req.Get("method").Str() == "Target.setDiscoverTargets" {
+					g.E(ws.Send([]byte(`{"method":"Test.event","params":{}}`)))
+				}
+			}
+		})
+		return s.HostURL.Host, methods
+	}
+	hostA, methodsA := newCDP()
+	hostB, methodsB := newCDP()
+
+	dir := t.TempDir()
+	hostFile := filepath.Join(dir, "host")
+	bin := filepath.Join(dir, "chrome")
+	g.E(os.WriteFile(hostFile, []byte(hostA), 0o644))
+	g.E(os.WriteFile(bin, []byte(fmt.Sprintf(
+		"#!/bin/sh\necho \"DevTools listening on ws://$(cat %s)/devtools/browser/id\"\nsleep 30\n", hostFile,
+	)), 0o755))
+
+	s := launcher.NewSupervisor(launcher.New().Bin(bin))
+	s.Sleeper = func() utils.Sleeper { return utils.BackoffSleeper(10*time.Millisecond, 10*time.Millisecond, nil) }
+	s.Limits = launcher.Limits{Memory: 1 << 40}
+
+	g.Has(s.MustStart(), hostA)
+	_, err := s.Start()
+	g.Eq(err, launcher.ErrAlreadyLaunched)
+
+	// without the Limits.Cgroup it falls back to the rlimits
+	if runtime.GOOS == "linux" {
+		g.Has(g.Read(fmt.Sprintf("/proc/%d/limits", s.PID())).String(), "1099511627776")
+	}
+
+	c := s.MustClient()
+	_, err = c.Call(g.Context(), "", "Target.setDiscoverTargets", map[string]bool{"discover": true})
+	g.E(err)
+	g.Eq(<-methodsA, "Target.setDiscoverTargets")
+	g.Eq((<-c.Event()).Method, "Test.event")
+
+	// crash the browser
+	restarts := s.Subscribe(g.Context())
+	g.E(os.WriteFile(hostFile, []byte(hostB), 0o644))
+	p, err := os.FindProcess(s.PID())
+	g.E(err)
+	g.E(p.Kill())
+
+	r := <-restarts
+	g.Has(r.URL, hostB)
+	g.Eq(r.Restarts, 1)
+	g.Is(r.Err, &launcher.LaunchError{})
+	g.Eq(s.Restarts(), 1)
+	g.Eq(s.URL(), r.URL)
+
+	// the client reconnects to the new browser and replays the target discovery
+	g.Eq(<-methodsB, "Target.setDiscoverTargets")
+	g.Eq((<-c.Event()).Method, "Test.event")
+	_, err = c.Call(g.Context(), "", "Browser.getVersion", nil)
+	g.E(err)
+	g.Eq(<-methodsB, "Browser.getVersion")
+
+	s.Stop()
+	g.Is(s.Err(), context.Canceled)
+	g.Eq(s.Launcher().ExitStatus().Signal, "killed")
+	for range c.Event() {
+	}
+}
+
+func TestLoad(t *testing.T) {
+	g := setup(t)
+
+	t.Setenv("ROD_TEST_PROXY", "proxy.test:1080")
+	t.Setenv("ROD_TEST_EMPTY", "")
+
+	dir := t.TempDir()
+	yml := filepath.Join(dir, "rod.yml")
+	g.E(os.WriteFile(yml, []byte(`
+# the shared settings
+base:
+  bin: /usr/bin/chromium
+  proxy: ${ROD_TEST_PROXY}
+  flags:
+    window-size: [1280, 720]
+    --mute-audio: true
+    disable-sync: false
+    lang: "en-US" # comment
+  env:
+    TZ: ${ROD_TEST_EMPTY:-UTC}
+    PRICE: $$1
+  preferences:
+    plugins:
+      always_open_pdf_externally: true
+  extensions:
+    - ext/a
+
+ci:
+  extends: base
+  revision: 1321438
+  flags:
+    disable-sync:
+    mute-audio: false
+  extensions: [/abs/b]
+`), 0o644))
+
+	ps := launcher.MustLoad(yml)
+
+	p, err := ps.Get("ci")
+	g.E(err)
+	g.Eq(p, &launcher.Profile{
+		Bin:      "/usr/bin/chromium",
+		Revision: 1321438,
+		Proxy:    "proxy.test:1080",
+		Flags: map[flags.Flag][]string{
+			"window-size":  {"1280", "720"},
+			"lang":         {"en-US"},
+			"disable-sync": nil,
+		},
+		RemoveFlags: []flags.Flag{"mute-audio"},
+		Env:         map[string]string{"TZ": "UTC", "PRICE": "$1"},
+		Preferences: `{"plugins":{"always_open_pdf_externally":true}}`,
+		Extensions:  []string{filepath.Join(dir, "ext/a"), "/abs/b"},
+	})
+
+	l := ps.MustLauncher("ci")
+	g.Eq(l.Get(flags.Bin), "/usr/bin/chromium")
+	g.Eq(l.Get(flags.ProxyServer), "proxy.test:1080")
+	g.Eq(l.Get(flags.WindowSize), "1280")
+	g.True(l.Has("disable-sync"))
+	g.False(l.Has("mute-audio"))
+	env, _ := l.GetFlags(flags.Env)
+	g.Eq(env[len(env)-2:], []string{"PRICE=$1", "TZ=UTC"})
+	g.Has(l.FormatArgs(), "--load-extension="+filepath.Join(dir, "ext/a")+",/abs/b")
+
+	base := ps.MustLauncher("base")
+	g.True(base.Has("mute-audio"))
+	g.False(base.Has("disable-sync"))
+
+	// json works the same
+	js := filepath.Join(dir, "rod.json")
+	g.E(os.WriteFile(js, []byte(`{"a": {"version": 120, "flags": {"headless": false}}, "b": {"extends": "a"}}`), 0o644))
+	p, err = launcher.MustLoad(js).Get("b")
+	g.E(err)
+	g.Eq(p.Version, "120")
+	g.Eq(p.RemoveFlags, []flags.Flag{flags.Headless})
+
+	// the profile option of defaults
+	wd, err := os.Getwd()
+	g.E(err)
+	sub := filepath.Join(dir, "sub")
+	g.E(os.Mkdir(sub, 0o755))
+	g.E(os.Chdir(sub))
+	defer func() { g.E(os.Chdir(wd)) }()
+	defaults.ResetWith("profile:ci")
+	defer defaults.ResetWith("")
+	l = launcher.New()
+	g.Eq(l.Get(flags.Bin), "/usr/bin/chromium") // rod.json isn't used because rod.yml comes first
+
+	defaults.ResetWith("profile=not-exists")
+	l = launcher.New()
+	_, err = l.Launch()
+	g.Is(err, launcher.ErrProfileNotFound)
+	g.Panic(func() { launcher.New().MustLaunch() })
+
+	for _, c := range []struct {
+		file, content, err string
+	}{
+		{"a.yml", "a: [1", "unexpected end"},
+		{"a.yml", "- a", "the top level should be the map of profiles"},
+		{"a.yml", "a:\n  unknown: 1", `profile "a": unknown: unknown field`},
+		{"a.yml", "a:\n  flags: [a]", `flags: should be a map`},
+		{"a.yml", "a:\n  revision: x", `revision: strconv.Atoi`},
+		{"a.yml", "a:\n  extends: b", `profile not found: b`},
+		{"a.yml", "a:\n  extends: b\nb:\n  extends: a", `extends itself`},
+		{"a.json", "{", "unexpected end of JSON input"},
+	} {
+		p := filepath.Join(dir, c.file)
+		g.E(os.WriteFile(p, []byte(c.content), 0o644))
+		_, err := launcher.Load(p)
+		g.Has(err.Error(), c.err)
+	}
+
+	_, err = launcher.MustLoad(yml).Get("x")
+	g.Is(err, launcher.ErrProfileNotFound)
+	_, err = launcher.MustLoad(yml).Launcher("x")
+	g.Is(err, launcher.ErrProfileNotFound)
+	_, err = launcher.Load(filepath.Join(dir, "not-exists.yml"))
+	g.True(errors.Is(err, os.ErrNotExist))
+}
+
+func TestDiscover(t *testing.T) {
+	g := setup(t)
+
+	if runtime.GOOS != "linux" {
+		g.Skip("the fake browsers use the linux names")
+	}
+
+	dir := t.TempDir()
+	fake := func(name, out string) string {
+		p := filepath.Join(dir, name)
+		g.E(os.WriteFile(p, []byte("#!/bin/sh\necho '"+out+"'\n"), 0o755))
+		return p
+	}
+	stable := fake("google-chrome", "Google Chrome 998.0.1.2")
+	beta := fake("google-chrome-beta", "Google Chrome 999.0.1.2 beta")
+	shell := fake("chrome-headless-shell", "Google Chrome for Testing 999.0.0.1")
+	g.E(os.Symlink(stable, filepath.Join(dir, "google-chrome-stable")))
+	t.Setenv("PATH", dir)
+
+	list := launcher.Discover()
+
+	found := map[string]*launcher.Discovered{}
+	for _, d := range list {
+		found[d.Bin] = d
+	}
+	g.Eq(found[stable], &launcher.Discovered{Bin: stable, Product: "chrome", Channel: "stable", Version: "998.0.1.2"})
+	g.Eq(found[beta], &launcher.Discovered{Bin: beta, Product: "chrome", Channel: "beta", Version: "999.0.1.2"})
+	g.Eq(found[shell], &launcher.Discovered{Bin: shell, Product: "chrome-headless-shell", Version: "999.0.0.1"})
+	g.Nil(found[filepath.Join(dir, "google-chrome-stable")])
+
+	d, err := launcher.FindBrowser(launcher.Requirement{MinVersion: "999.0.1"})
+	g.E(err)
+	g.Eq(d.Bin, beta)
+
+	d, err = launcher.FindBrowser(launcher.Requirement{Product: "chrome", Channel: "stable", MinVersion: "998"})
+	g.E(err)
+	g.Eq(d.Bin, stable)
+
+	_, err = launcher.FindBrowser(launcher.Requirement{MinVersion: "1000"})
+	g.Is(err, launcher.ErrBrowserNotFound)
+	g.Has(err.Error(), beta)
+}
+
 func TestLaunchMultiTimes(t *testing.T) {
 	g := setup(t)
 
@@ -325,3 +1124,655 @@ func TestLaunchMultiTimes(t *testing.T) {
 	_, e = l.Launch()
 	g.Eq(e, launcher.ErrAlreadyLaunched)
 }
+
+// newFakeBrowser returns a shell script that acts as a browser, it serves a cdp endpoint that
+// has a single page and responds an empty result to other calls.
+func newFakeBrowser(g got.G) string {
+	if runtime.GOOS == "windows" {
+		g.Skip("the fake browsers are shell scripts")
+	}
+
+	s := g.Serve()
+	s.Route("/json/version", ".json", `{"webSocketDebuggerUrl": "ws://a/devtools/browser/id"}`)
+	s.Mux.HandleFunc("/devtools/browser/", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := mux.Upgrade(w, r)
+		g.E(err)
+		for {
+			data, err := ws.Read()
+			if err != nil {
+				return
+			}
+			req := gson.New(data)
+			res := map[string]string{
+				"Target.getTargets": `{"targetInfos":[{"targetId":"t1","type":"page","title":"a",` +
+					`"url":"about:blank","attached":false,"canAccessOpener":false}]}`,
+				"Target.attachToTarget":  `{"sessionId":"s1"}`,
+				"Page.captureScreenshot": `{"data":"cG5n"}`,
+			}[req.Get("method").Str()]
+			if res == "" {
+				res = "{}"
+			}
+			if ws.Send([]byte(fmt.Sprintf(`{"id":%d,"result":%s}`, req.Get("id").Int(), res))) != nil {
+				return
+			}
+
+			// pretend the browser accepted a connection on the bound port
+			if req.Get("method").Str() == "Tethering.bind" {
+				_ = ws.Send([]byte(fmt.Sprintf(`{"method":"Tethering.accepted","params":{"port":%d,"connectionId":"127.0.0.1:%d"}}`,
+					req.Get("params.port").Int(), req.Get("params.port").Int())))
+			}
+		}
+	})
+
+	bin := filepath.Join(g.Testable.(*testing.T).TempDir(), "chrome")
+	g.E(os.WriteFile(bin, []byte(fmt.Sprintf(
+		"#!/bin/sh\n"+
+			"for a in \"$@\"; do case $a in --user-data-dir=*) mkdir -p \"${a#*=}\";; esac; done\n"+
+			"echo \"display: $DISPLAY\"\n"+
+			"echo \"DevTools listening on ws://%s/devtools/browser/id\"\n"+
+			"echo fake-stderr >&2\n"+
+			"sleep 30\n", s.HostURL.Host,
+	)), 0o755))
+
+	return bin
+}
+
+func TestManagerPolicy(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	t.Setenv("ROD_TEST_TOKEN", "token-b")
+	conf := launcher.MustLoadManagerConfig(filepath.Join("fixtures", "manager.yml"))
+	g.Eq(conf.AllowedFlags, []flags.Flag{"lang"})
+	g.Eq(conf.Tokens["team-b"], "token-b")
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	launcher.MustLoadManagerConfig(filepath.Join("fixtures", "manager.yml")).Apply(m)
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+	host := s.HostURL.Host
+
+	connect := func(token string, set func(*launcher.Launcher)) (*cdp.Client, error) {
+		l, err := launcher.NewManaged("ws://" + token + "@" + host)
+		g.E(err)
+		l.Bin(bin)
+		if set != nil {
+			set(l)
+		}
+		u, h := l.ClientHeader()
+		return cdp.StartWithURL(g.Context(), u, h)
+	}
+	body := func(err error) string {
+		var e *cdp.BadHandshakeError
+		g.True(errors.As(err, &e))
+		return e.Body
+	}
+
+	_, err := launcher.NewManaged("ws://" + host)
+	g.Eq(err.Error(), "401 Unauthorized: [rod-manager] unauthorized: missing bearer token")
+	_, err = launcher.NewManaged("ws://wrong@" + host)
+	g.Eq(err.Error(), "401 Unauthorized: [rod-manager] unauthorized: invalid bearer token")
+
+	_, err = connect("token-a", func(l *launcher.Launcher) { l.Set("mute-audio") })
+	g.Eq(body(err), "[rod-manager] flag not allowed: --mute-audio\n")
+
+	a, err := connect("token-a", func(l *launcher.Launcher) { l.Set("lang", "en") })
+	g.E(err)
+	_, err = a.Call(g.Context(), "", "Browser.getVersion", nil)
+	g.E(err)
+
+	_, err = connect("token-a", nil)
+	g.Eq(body(err), "[rod-manager] quota exceeded: client team-a has 1 sessions\n")
+
+	// the max sessions is 1, so the client of team-b has to wait in the queue
+	_, err = connect("token-b", nil)
+	g.Eq(body(err), "[rod-manager] queue timeout after 500ms\n")
+	g.Gte(metrics.ManagerRejections.Get("queue_timeout"), 1)
+
+	// the session of team-a is killed because it's idle
+	for range a.Event() {
+	}
+	g.Gte(metrics.ManagerKills.Get("idle"), 1)
+
+	// the slot is released after the cleanup
+	for ctx := g.Timeout(5 * time.Second); ; {
+		b, err := connect("token-b", nil)
+		if err == nil {
+			_, err = b.Call(g.Context(), "", "Browser.getVersion", nil)
+			g.E(err)
+			break
+		}
+		g.E(ctx.Err())
+		utils.Sleep(0.1)
+	}
+}
+
+func TestManagerQueue(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	m.MaxSessions = 1
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+
+	connect := func() (*cdp.WebSocket, error) {
+		u, h := launcher.MustNewManaged(s.URL()).Bin(bin).ClientHeader()
+		ws := &cdp.WebSocket{}
+		return ws, ws.Connect(g.Context(), u, h)
+	}
+
+	first, err := connect()
+	g.E(err)
+
+	type session struct {
+		i  int
+		ws *cdp.WebSocket
+	}
+	sessions := make(chan session, 2)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			ws, err := connect()
+			if err == nil {
+				sessions <- session{i, ws}
+			}
+		}(i)
+		// make sure the requests are queued in order
+		for metrics.ManagerQueued.Get("") < float64(i) {
+			utils.Sleep(0.01)
+		}
+	}
+
+	// closing the websocket ends the session, then the queued requests launch in FIFO order
+	g.E(first.Close())
+	next := <-sessions
+	g.Eq(next.i, 1)
+	g.E(next.ws.Close())
+	next = <-sessions
+	g.Eq(next.i, 2)
+	g.E(next.ws.Close())
+}
+
+func TestManagerPool(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	m.PoolSize = 1
+	m.PoolLauncher = func() *launcher.Launcher { return launcher.New().Bin(bin) }
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+
+	m.StartPool()
+	m.StartPool()
+	defer m.StopPool()
+
+	waitIdle := func() {
+		for ctx := g.Timeout(5 * time.Second); m.PoolStats().Idle < 1; {
+			g.E(ctx.Err())
+			utils.Sleep(0.01)
+		}
+	}
+
+	connect := func(set func(*launcher.Launcher)) *cdp.WebSocket {
+		l, err := launcher.NewManaged(s.URL())
+		g.E(err)
+		set(l.Bin(bin))
+		u, h := l.ClientHeader()
+		ws := &cdp.WebSocket{}
+		g.E(ws.Connect(g.Context(), u, h))
+		return ws
+	}
+
+	waitIdle()
+	g.E(connect(func(*launcher.Launcher) {}).Close())
+	g.Eq(m.PoolStats().Hits, int64(1))
+
+	// the pool is refilled in the background
+	waitIdle()
+	g.E(connect(func(l *launcher.Launcher) { l.Set("mute-audio") }).Close())
+	g.Eq(m.PoolStats(), launcher.PoolStats{Size: 1, Idle: 1, Hits: 1, Misses: 1})
+	g.Eq(m.PoolStats().HitRate(), 0.5)
+	g.Eq(launcher.PoolStats{}.HitRate(), 0.0)
+
+	// the user data dir of the pre-launched browser is moved to the one the client requested
+	var dir string
+	g.E(connect(func(l *launcher.Launcher) {
+		l.KeepUserDataDir()
+		dir = l.Get(flags.UserDataDir)
+	}).Close())
+	for ctx := g.Timeout(5 * time.Second); !g.PathExists(dir); {
+		g.E(ctx.Err())
+		utils.Sleep(0.1)
+	}
+	g.Eq(m.PoolStats().Hits, int64(2))
+	g.E(os.RemoveAll(dir))
+}
+
+func TestManagerAdmin(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+	s.Mux.Handle("/admin/", http.StripPrefix("/admin", m.AdminHandler(launcher.BearerAuth(map[string]string{"admin": "secret"}))))
+
+	api := func(method, path string) *got.ResHelper {
+		return g.Req(method, s.URL("/admin"+path+"?access_token=secret"))
+	}
+
+	g.Eq(g.Req("", s.URL("/admin/sessions")).StatusCode, http.StatusUnauthorized)
+	g.Eq(api("", "/sessions").String(), "[]")
+
+	u, h := launcher.MustNewManaged(s.URL()).Bin(bin).ClientHeader()
+	ws := &cdp.WebSocket{}
+	g.E(ws.Connect(g.Context(), u, h))
+	client := cdp.New().Start(ws)
+
+	list := m.Sessions()
+	g.Len(list, 1)
+	id := list[0].ID
+	g.Eq(list[0].Client, "127.0.0.1")
+	g.Gt(list[0].PID, 0)
+	g.Eq(api("", "/sessions").JSON().([]interface{})[0].(map[string]interface{})["id"], id)
+
+	for ctx := g.Timeout(5 * time.Second); !strings.Contains(api("", "/sessions/"+id+"/log").String(), "fake-stderr"); {
+		g.E(ctx.Err())
+		utils.Sleep(0.01)
+	}
+	g.Has(api("", "/sessions/"+id+"/log").String(), "DevTools listening")
+	g.Eq(api("", "/sessions/"+id+"/pages").JSON().([]interface{})[0].(map[string]interface{})["targetId"], "t1")
+	screenshot := api("", "/sessions/"+id+"/screenshot")
+	g.Eq(screenshot.Header.Get("Content-Type"), "image/png")
+	g.Eq(screenshot.String(), "png")
+
+	g.Eq(api("", "/sessions/not-exists/log").StatusCode, http.StatusNotFound)
+	g.Eq(api("", "/unknown").StatusCode, http.StatusNotFound)
+	g.Eq(api("", "/pool").JSON().(map[string]interface{})["size"], 0.0)
+
+	g.Eq(api(http.MethodPost, "/sessions/"+id+"/kill").StatusCode, http.StatusOK)
+	for range client.Event() {
+	}
+	for ctx := g.Timeout(5 * time.Second); len(m.Sessions()) > 0; {
+		g.E(ctx.Err())
+		utils.Sleep(0.01)
+	}
+	g.Eq(m.KillSession(id).Error(), "session not found: "+id)
+}
+
+func TestManagerReattach(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	m.MaxReattachGrace = 10 * time.Second
+	s := g.Serve()
+	remoteAddr := ""
+	s.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if remoteAddr != "" {
+			r.RemoteAddr = remoteAddr
+		}
+		m.ServeHTTP(w, r)
+	})
+
+	call := func(c *cdp.Client) error {
+		_, err := c.Call(g.Context(), "", "Browser.getVersion", nil)
+		return err
+	}
+
+	l := launcher.MustNewManaged(s.URL()).Bin(bin).ReattachGrace(time.Minute)
+	g.Eq(l.Get(flags.ReattachGrace), "60")
+	first := l.MustClient()
+	g.E(call(first))
+	id := l.SessionID()
+	g.Len(id, 32)
+	g.Eq(m.Sessions()[0].ID, id)
+
+	// the following client of the same launcher takes over the session
+	second := l.MustClient()
+	for range first.Event() {
+	}
+	g.E(call(second))
+	g.Len(m.Sessions(), 1)
+
+	// reattach after the disconnect
+	u, h := launcher.MustNewManaged(s.URL()).Reattach(id).ClientHeader()
+	ws := &cdp.WebSocket{}
+	g.E(ws.Connect(g.Context(), u, h))
+	g.Eq(ws.ResponseHeader.Get(launcher.SessionHeaderName), id)
+	for range second.Event() {
+	}
+	g.E(ws.Close())
+
+	// without the auth the client may reattach from a new IP
+	remoteAddr = "10.0.0.2:1234"
+	third, err := launcher.MustNewManaged(s.URL()).Reattach(id).Client()
+	g.E(err)
+	g.E(call(third))
+	g.Len(m.Sessions(), 1)
+
+	// so does the access to the files of the session
+	req, err := http.NewRequestWithContext(g.Context(), http.MethodGet, s.URL("/files"), nil)
+	g.E(err)
+	req.Header.Set(launcher.SessionHeaderName, id)
+	res, err := http.DefaultClient.Do(req)
+	g.E(err)
+	g.E(res.Body.Close())
+	g.Eq(res.StatusCode, http.StatusOK)
+	remoteAddr = ""
+
+	// with the auth only the same client can reattach
+	m.Auth = func(*http.Request) (string, error) { return "other", nil }
+	_, err = launcher.MustNewManaged(s.URL()).Reattach(id).Client()
+	g.Has(err.Error(), "session not found")
+	m.Auth = nil
+
+	_, err = launcher.MustNewManaged(s.URL()).Reattach("not-exists").Client()
+	var e *cdp.BadHandshakeError
+	g.True(errors.As(err, &e))
+	g.Eq(e.Body, "[rod-manager] session not found: not-exists\n")
+
+	// the browser is cleaned up if no client reattaches within the grace period
+	short := launcher.MustNewManaged(s.URL()).Bin(bin).ReattachGrace(500 * time.Millisecond)
+	g.E(call(short.MustClient()))
+	g.Len(m.Sessions(), 2)
+	u, h = short.ClientHeader()
+	ws = &cdp.WebSocket{}
+	g.E(ws.Connect(g.Context(), u, h))
+	g.E(ws.Close())
+	for ctx := g.Timeout(10 * time.Second); len(m.Sessions()) > 1; {
+		g.E(ctx.Err())
+		utils.Sleep(0.1)
+	}
+	g.Gte(metrics.ManagerReattaches.Get("timeout"), 1)
+	g.Gte(metrics.ManagerReattaches.Get("ok"), 3)
+
+	// no session id without the grace period
+	plain := launcher.MustNewManaged(s.URL()).Bin(bin)
+	g.E(call(plain.MustClient()))
+	g.Eq(plain.SessionID(), "")
+}
+
+func TestBalancer(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	newManager := func(max int) (*launcher.Manager, string) {
+		m := launcher.NewManager()
+		m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+		m.MaxSessions = max
+		m.MaxReattachGrace = 10 * time.Second
+		s := g.Serve()
+		s.Mux.Handle("/", m)
+		return m, s.HostURL.Host
+	}
+
+	a, hostA := newManager(1)
+	a.Auth = launcher.ForwardedAuth(launcher.BearerAuth(map[string]string{"balancer": "secret", "c": "token-c"}), "balancer")
+	b, hostB := newManager(2)
+
+	balancer := launcher.MustNewBalancer("ws://secret@"+hostA, "ws://"+hostB, "ws://127.0.0.1:1")
+	balancer.Interval = time.Hour
+	balancer.Start()
+	balancer.Start()
+	defer balancer.Stop()
+
+	list := balancer.Backends()
+	g.Eq([]bool{list[0].Healthy, list[1].Healthy, list[2].Healthy}, []bool{true, true, false})
+	g.Eq(list[1].Capacity, launcher.ManagerCapacity{MaxSessions: 2})
+	g.NotZero(list[2].Error)
+
+	s := g.Serve()
+	s.Mux.Handle("/", balancer)
+
+	connect := func() *launcher.Launcher {
+		l := launcher.MustNewManaged(s.URL()).Bin(bin).ReattachGrace(time.Minute)
+		_, err := l.MustClient().Call(g.Context(), "", "Browser.getVersion", nil)
+		g.E(err)
+		return l
+	}
+
+	// the launches go to the manager that has the most free slots
+	connect()
+	g.Eq([]int{len(a.Sessions()), len(b.Sessions())}, []int{0, 1})
+	connect()
+	g.Eq([]int{len(a.Sessions()), len(b.Sessions())}, []int{1, 1})
+	l := connect()
+	g.Eq([]int{len(a.Sessions()), len(b.Sessions())}, []int{1, 2})
+
+	// the manager sees the client of the balancer
+	g.Eq(a.Sessions()[0].Client, "balancer/127.0.0.1")
+
+	// only the balancer can forward the client name
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer token-c")
+	req.Header.Set(launcher.ClientHeaderName, "balancer")
+	client, err := a.Auth(req)
+	g.E(err)
+	g.Eq(client, "c")
+
+	// the reattach goes to the manager of the session
+	g.Has(l.SessionID(), "1.")
+	l.MustClient()
+	g.Len(b.Sessions(), 2)
+
+	_, err = launcher.MustNewManaged(s.URL()).Reattach("9.id").Client()
+	var e *cdp.BadHandshakeError
+	g.True(errors.As(err, &e))
+	g.Eq(e.Body, "[rod-balancer] session not found: 9.id\n")
+
+	backends := func(b *launcher.Balancer) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		b.ServeBackends(res, httptest.NewRequest(http.MethodGet, "/backends", nil))
+		return res
+	}
+	g.Has(backends(balancer).Body.String(), hostB)
+	balancer.Auth = launcher.BearerAuth(map[string]string{"a": "secret"})
+	g.Eq(backends(balancer).Code, http.StatusUnauthorized)
+
+	dead := launcher.MustNewBalancer("ws://127.0.0.1:1")
+	dead.Start()
+	defer dead.Stop()
+	s = g.Serve()
+	s.Mux.Handle("/", dead)
+	_, err = launcher.NewManaged(s.URL())
+	g.Eq(err.Error(), "503 Service Unavailable: [rod-balancer] no healthy manager")
+}
+
+func TestManagerFiles(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	m.Auth = launcher.BearerAuth(map[string]string{"a": "token-a", "b": "token-b"})
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+	host := s.HostURL.Host
+
+	c := launcher.MustNewManaged("ws://token-a@" + host).Bin(bin).MustManagedClient()
+
+	dir, err := c.RemoteDir(g.Context())
+	g.E(err)
+	g.True(g.PathExists(dir))
+
+	p, err := c.UploadFile(g.Context(), "uploads/a.txt", strings.NewReader("ok"))
+	g.E(err)
+	g.Eq(p, filepath.Join(dir, "uploads", "a.txt"))
+	g.Eq(g.Read(p).String(), "ok")
+
+	buf := bytes.NewBuffer(nil)
+	g.E(c.DownloadFile(g.Context(), "uploads/a.txt", buf))
+	g.Eq(buf.String(), "ok")
+
+	g.Eq(c.DownloadFile(g.Context(), "not-exists", buf).Error(), "404 Not Found: [rod-manager] file not found: not-exists")
+	_, err = c.UploadFile(g.Context(), "../a.txt", strings.NewReader("ok"))
+	g.Err(err)
+	g.False(g.PathExists(filepath.Join(filepath.Dir(dir), "a.txt")))
+
+	// the files of the session are private to its client
+	other := launcher.MustNewManaged("ws://token-b@" + host).Bin(bin).MustManagedClient()
+	id := m.Sessions()[0].ID
+	req, err := http.NewRequestWithContext(g.Context(), http.MethodGet, s.URL("/files"), nil)
+	g.E(err)
+	req.Header.Set("Authorization", "Bearer token-b")
+	req.Header.Set(launcher.SessionHeaderName, id)
+	res, err := http.DefaultClient.Do(req)
+	g.E(err)
+	g.E(res.Body.Close())
+	g.Eq(res.StatusCode, http.StatusNotFound)
+	_, err = other.RemoteDir(g.Context())
+	g.E(err)
+
+	// the dir is removed after the session
+	g.E(m.KillSession(id))
+	for ctx := g.Timeout(5 * time.Second); g.PathExists(dir); {
+		g.E(ctx.Err())
+		utils.Sleep(0.1)
+	}
+}
+
+func TestManagerTether(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+
+	m := launcher.NewManager()
+	m.BeforeLaunch = func(*launcher.Launcher, http.ResponseWriter, *http.Request) {}
+	s := g.Serve()
+	s.Mux.Handle("/", m)
+
+	c := launcher.MustNewManaged(s.URL()).Bin(bin).MustManagedClient()
+
+	// a fake tethering socket that echoes in upper case
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	g.E(err)
+	defer func() { _ = ln.Close() }()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		buf := make([]byte, 16)
+		n, _ := conn.Read(buf)
+		_, _ = conn.Write(bytes.ToUpper(buf[:n]))
+	}()
+
+	// only the accepted connections can be opened
+	var e *cdp.BadHandshakeError
+	_, err = c.Tether(g.Context(), ln.Addr().String())
+	g.True(errors.As(err, &e))
+	g.Eq(e.Body, "[rod-manager] tethering connection not found: "+ln.Addr().String()+"\n")
+
+	_, err = c.Call(g.Context(), "", "Tethering.bind", map[string]int{"port": ln.Addr().(*net.TCPAddr).Port})
+	g.E(err)
+	for e := range c.Event() {
+		if e.Method == "Tethering.accepted" {
+			break
+		}
+	}
+
+	stream, err := c.Tether(g.Context(), ln.Addr().String())
+	g.E(err)
+	_, err = stream.Write([]byte("ok"))
+	g.E(err)
+	buf := make([]byte, 16)
+	n, err := stream.Read(buf)
+	g.E(err)
+	g.Eq(string(buf[:n]), "OK")
+	g.E(stream.Close())
+
+	// each accepted connection can be opened once
+	_, err = c.Tether(g.Context(), ln.Addr().String())
+	g.True(errors.As(err, &e))
+	g.Eq(e.Body, "[rod-manager] tethering connection not found: "+ln.Addr().String()+"\n")
+}
+
+func TestXvfbScreen(t *testing.T) {
+	g := setup(t)
+
+	bin := newFakeBrowser(g)
+	dir := t.TempDir()
+
+	// a 2x1 xwd of a red pixel and a blue pixel, 32 bits per pixel in LSBFirst
+	xwd := bytes.NewBuffer(nil)
+	g.E(binary.Write(xwd, binary.BigEndian, []uint32{
+		104, 7, 2, 24, 2, 1, 0, 0, 32, 0, 32, 32, 8, 4, 0xff0000, 0xff00, 0xff, 8, 256, 0, 2, 1, 0, 0, 0,
+	}))
+	xwd.WriteString("rod\x00")
+	g.E(binary.Write(xwd, binary.LittleEndian, []uint32{0xff0000, 0xff}))
+	g.E(os.WriteFile(filepath.Join(dir, "screen.xwd"), xwd.Bytes(), 0o644))
+	t.Setenv("ROD_TEST_XWD", filepath.Join(dir, "screen.xwd"))
+
+	g.E(os.WriteFile(filepath.Join(dir, "Xvfb"), []byte(
+		"#!/bin/sh\n"+
+			"while [ $# -gt 0 ]; do case $1 in -displayfd) fd=$2;; -fbdir) fb=$2;; -screen) screen=$3;; esac; shift; done\n"+
+			"[ \"$screen\" = 1280x720x24 ] || { echo bad screen $screen >&2; exit 1; }\n"+
+			"cp \"$ROD_TEST_XWD\" \"$fb/Xvfb_screen0\"\n"+
+			"eval \"echo 99 >&$fd\"\n"+
+			"exec sleep 30\n",
+	), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	l := launcher.New().Bin(bin).Headless(false).XvfbScreen(1280, 720, 24)
+	g.Eq(l.XvfbDisplay(), "")
+	l.MustLaunch()
+
+	g.Eq(l.XvfbDisplay(), ":99")
+	g.Has(l.Output(), "display: :99")
+
+	img, err := l.XvfbScreenshot()
+	g.E(err)
+	g.Eq(img.Bounds().Dx(), 2)
+	g.Eq(img.At(0, 0), color.RGBA{R: 0xff, A: 0xff})
+	g.Eq(img.At(1, 0), color.RGBA{B: 0xff, A: 0xff})
+
+	// the xvfb is stopped when the browser is reaped by others
+	p, err := os.FindProcess(l.PID())
+	g.E(err)
+	g.E(p.Kill())
+	l.Cleanup()
+	g.Eq(l.XvfbDisplay(), "")
+	_, err = l.XvfbScreenshot()
+	g.Is(err, launcher.ErrXvfb)
+	g.Has(err.Error(), "not running")
+
+	l = launcher.New().Bin(bin).XvfbScreen(1, 1, 8)
+	_, err = l.Launch()
+	g.Is(err, launcher.ErrXvfb)
+	g.Has(err.Error(), "bad screen 1x1x8")
+
+	_, err = launcher.New().Bin(bin).StrictFlags(true).XVFB().XvfbScreen(1280, 720, 24).Launch()
+	g.Has(err.Error(), "conflicts")
+
+	_, err = launcher.New().Bin(bin).StrictFlags(true).Set(flags.XvfbScreen, "1280x720").Launch()
+	g.Has(err.Error(), `should be like "1280x720x24"`)
+}
+
+func TestFakeMedia(t *testing.T) {
+	g := setup(t)
+
+	l := launcher.New().FakeMedia("a.y4m", "b.wav")
+	g.Has(l.FormatArgs(), "--use-fake-device-for-media-stream")
+	g.Has(l.FormatArgs(), "--use-file-for-fake-video-capture=a.y4m")
+	g.Has(l.FormatArgs(), "--use-file-for-fake-audio-capture=b.wav")
+
+	l = launcher.New().FakeMedia("", "")
+	g.True(l.Has("use-fake-device-for-media-stream"))
+	g.False(l.Has("use-file-for-fake-video-capture"))
+	g.False(l.Has("use-file-for-fake-audio-capture"))
+}
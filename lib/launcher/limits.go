@@ -0,0 +1,22 @@
+package launcher
+
+// Limits of the resources the browser processes can use, the zero values mean no limit.
+// On Linux with cgroups v2 and the [Limits.Cgroup], all the processes of the browser are put into a cgroup
+// that has the limits. Otherwise only the memory limit is supported, it falls back to the RLIMIT_DATA of each
+// process, so it limits each process of the browser instead of their total.
+type Limits struct {
+	// Memory in bytes
+	Memory int64
+
+	// CPU is the number of CPUs, such as 0.5 means half of a CPU.
+	CPU float64
+
+	// Cgroup is the dir of a cgroups v2 group delegated to rod, such as "/sys/fs/cgroup/rod", the group of
+	// each browser is created inside it. The group shouldn't have processes, such as the current process,
+	// because of the no internal process rule of cgroups v2. It's required by the cgroup based limits.
+	Cgroup string
+}
+
+func (l Limits) empty() bool {
+	return l.Memory <= 0 && l.CPU <= 0
+}
@@ -0,0 +1,166 @@
+//go:build linux
+
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// applyLimits moves the processes of the browser into a new group of the [Limits.Cgroup] with the limits,
+// if the group isn't set or cgroups v2 isn't available it falls back to the per-process rlimits.
+// It never changes the cgroup of the current process. The release removes the cgroup.
+func applyLimits(pid int, limits Limits) (release func(), err error) {
+	release = func() {}
+
+	if limits.empty() {
+		return release, nil
+	}
+
+	dir, cgErr := newCgroup(pid, limits)
+	if cgErr == nil {
+		return func() { removeCgroup(dir) }, nil
+	}
+
+	if limits.CPU > 0 {
+		return release, fmt.Errorf("the cpu limit requires the Limits.Cgroup of cgroups v2: %w", cgErr)
+	}
+
+	// RLIMIT_AS breaks the browser because V8 reserves a lot of virtual memory, so we limit the data segment.
+	// It's per-process, so it doesn't cap the total memory of the browser.
+	for _, p := range groupPIDs(pid) {
+		lim := syscall.Rlimit{Cur: uint64(limits.Memory), Max: uint64(limits.Memory)}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(p), syscall.RLIMIT_DATA, uintptr(unsafe.Pointer(&lim)), 0, 0, 0)
+		if errno != 0 {
+			return release, fmt.Errorf("failed to set the rlimit of process %d: %w", p, errno)
+		}
+	}
+
+	return release, nil
+}
+
+func newCgroup(pid int, limits Limits) (string, error) {
+	_, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+
+	controllers := []string{}
+	if limits.Memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if limits.CPU > 0 {
+		controllers = append(controllers, "cpu")
+	}
+
+	parent, err := cgroupParent(limits.Cgroup, controllers)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("rod-browser-%d", pid))
+	err = os.Mkdir(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+
+	set := func(name, value string) {
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+		}
+	}
+
+	if limits.Memory > 0 {
+		set("memory.max", strconv.FormatInt(limits.Memory, 10))
+	}
+	if limits.CPU > 0 {
+		const period = 100000
+		set("cpu.max", fmt.Sprintf("%d %d", int64(limits.CPU*period), period))
+	}
+	for _, p := range groupPIDs(pid) {
+		set("cgroup.procs", strconv.Itoa(p))
+	}
+
+	if err != nil {
+		removeCgroup(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// cgroupParent enables the controllers for the children of the delegated group. Because of the no internal
+// process rule of cgroups v2, the delegated group shouldn't have processes itself.
+func cgroupParent(delegated string, controllers []string) (string, error) {
+	if delegated == "" {
+		return "", errors.New("the Limits.Cgroup isn't set")
+	}
+
+	b, err := os.ReadFile(filepath.Join(delegated, "cgroup.subtree_control"))
+	if err != nil {
+		return "", err
+	}
+	enabled := strings.Fields(string(b))
+	for _, c := range controllers {
+		if !slices.Contains(enabled, c) {
+			err = os.WriteFile(filepath.Join(delegated, "cgroup.subtree_control"), []byte("+"+c), 0)
+			if err != nil {
+				return "", fmt.Errorf("failed to enable the %s controller of %s, the group shouldn't have processes: %w",
+					c, delegated, err)
+			}
+		}
+	}
+
+	return delegated, nil
+}
+
+// removeCgroup waits for the processes in the cgroup to exit, then removes it.
+func removeCgroup(dir string) {
+	for i := 0; i < 50; i++ {
+		if os.Remove(dir) == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// groupPIDs returns the processes in the process group, the browser is launched as a group leader.
+func groupPIDs(pgid int) []int {
+	list := []int{pgid}
+
+	entries, _ := os.ReadDir("/proc")
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == pgid {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+
+		// the format is "pid (comm) state ppid pgrp ...", the comm may contain spaces
+		i := strings.LastIndexByte(string(b), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(b[i+1:]))
+		if len(fields) > 2 && fields[2] == strconv.Itoa(pgid) {
+			list = append(list, pid)
+		}
+	}
+
+	return list
+}
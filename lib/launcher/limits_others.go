@@ -0,0 +1,12 @@
+//go:build !linux
+
+package launcher
+
+import "errors"
+
+func applyLimits(_ int, limits Limits) (release func(), err error) {
+	if limits.empty() {
+		return func() {}, nil
+	}
+	return func() {}, errors.New("the resource limits are only supported on Linux")
+}
@@ -0,0 +1,117 @@
+package launcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/halicoming/rod/lib/cdp"
+)
+
+// ManagedClient is the cdp client of a browser launched by the [Manager]. Besides the cdp, it can transfer
+// files with the working dir of the session on the host of the manager, such as the files to upload or
+// the downloaded files of the browser, and open the side-channels of the tethering connections.
+// Rod uses them automatically, check rod.RemoteFiles and rod.Tetherable. Get it via [Launcher.ManagedClient].
+type ManagedClient struct {
+	*cdp.Client
+
+	u      *url.URL // the http url of the manager
+	header http.Header
+
+	lock sync.Mutex
+	dir  string
+}
+
+func newManagedClient(c *cdp.Client, serviceURL string, header http.Header, id string) *ManagedClient {
+	u, _ := url.Parse(serviceURL)
+	u = toHTTP(*u)
+
+	header = header.Clone()
+	header.Del(HeaderName)
+	header.Set(SessionHeaderName, id)
+
+	return &ManagedClient{Client: c, u: u, header: header}
+}
+
+// UploadFile to the working dir of the session, the name is the slash separated path relative to the dir.
+// It returns the path of the file on the host of the manager.
+func (c *ManagedClient) UploadFile(ctx context.Context, name string, r io.Reader) (string, error) {
+	res, err := c.do(ctx, http.MethodPut, name, r)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var data struct{ Path string }
+	return data.Path, json.NewDecoder(res.Body).Decode(&data)
+}
+
+// DownloadFile from the working dir of the session, the name is the slash separated path relative to the dir.
+func (c *ManagedClient) DownloadFile(ctx context.Context, name string, w io.Writer) error {
+	res, err := c.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// RemoteDir returns the path of the working dir of the session on the host of the manager.
+func (c *ManagedClient) RemoteDir(ctx context.Context) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.dir != "" {
+		return c.dir, nil
+	}
+
+	res, err := c.do(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	var data struct{ Dir string }
+	err = json.NewDecoder(res.Body).Decode(&data)
+	c.dir = data.Dir
+	return c.dir, err
+}
+
+func (c *ManagedClient) do(ctx context.Context, method, name string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.u.JoinPath("files", name).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.header.Clone()
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer func() { _ = res.Body.Close() }()
+		b, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(b)))
+	}
+
+	return res, nil
+}
+
+// Tether opens the side-channel of the tethering connection of the browser, the connectionID is from the
+// proto.TetheringAccepted event. Check the rod.Browser.Tether.
+func (c *ManagedClient) Tether(ctx context.Context, connectionID string) (io.ReadWriteCloser, error) {
+	ws := &cdp.WebSocket{}
+	err := ws.Connect(ctx, toWS(*c.u).JoinPath("tether", connectionID).String(), c.header)
+	if err != nil {
+		return nil, err
+	}
+	return &wsStream{ws: ws}, nil
+}
@@ -1,22 +1,40 @@
 package launcher
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/halicoming/rod/lib/cdp"
 	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/utils"
 )
 
 const (
 	// HeaderName for remote launch.
 	HeaderName = "Rod-Launcher"
+
+	// SessionHeaderName for the session reattach, check [Launcher.ReattachGrace].
+	SessionHeaderName = "Rod-Session-Id"
+
+	// CapacityHeaderName of the response of the defaults request, its value is the json of [ManagerCapacity].
+	CapacityHeaderName = "Rod-Manager-Capacity"
+
+	// ClientHeaderName is set by the [Balancer] to the name of the client it authenticated, check [ForwardedAuth].
+	ClientHeaderName = "Rod-Client"
 )
 
 // MustNewManaged is similar to NewManaged.
@@ -36,6 +54,8 @@ func MustNewManaged(serviceURL string) *Launcher {
 // to get the default settings of the Launcher instance. For example if the launcher.Manager running on a
 // Linux machine will return different default settings from the one on Mac.
 // If Launcher.Leakless is enabled, the remote browser will be killed after the websocket is closed.
+// If the manager requires the [BearerAuth], put the token in the user info of the serviceURL,
+// such as "ws://token@a.com:7317".
 func NewManaged(serviceURL string) (*Launcher, error) {
 	if serviceURL == "" {
 		serviceURL = "ws://127.0.0.1:7317"
@@ -48,15 +68,37 @@ func NewManaged(serviceURL string) (*Launcher, error) {
 
 	l := New()
 	l.managed = true
-	l.serviceURL = toWS(*u).String()
+	l.managedHeader = http.Header{}
 	l.Flags = nil
 
-	res, err := http.Get(toHTTP(*u).String()) //nolint: noctx
+	if u.User != nil {
+		token, has := u.User.Password()
+		if !has {
+			token = u.User.Username()
+		}
+		l.managedHeader.Set("Authorization", "Bearer "+token)
+		u.User = nil
+	}
+
+	l.serviceURL = toWS(*u).String()
+
+	req, err := http.NewRequest(http.MethodGet, toHTTP(*u).String(), nil) //nolint: noctx
+	if err != nil {
+		return nil, err
+	}
+	req.Header = l.managedHeader.Clone()
+
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = res.Body.Close() }()
 
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(b)))
+	}
+
 	return l, json.NewDecoder(res.Body).Decode(l)
 }
 
@@ -67,6 +109,30 @@ func (l *Launcher) KeepUserDataDir() *Launcher {
 	return l
 }
 
+// ReattachGrace keeps the remote browser alive for the duration after the websocket is disconnected.
+// The manager returns a session id when the [Launcher.Client] connects, the following calls of
+// [Launcher.Client] of the same Launcher reconnect to the same browser within the duration, to
+// reconnect from another Launcher use [Launcher.Reattach]. The manager may shorten the duration,
+// check [Manager.MaxReattachGrace].
+func (l *Launcher) ReattachGrace(d time.Duration) *Launcher {
+	l.mustManaged()
+	return l.Set(flags.ReattachGrace, strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// Reattach to the remote browser of the session id returned by the [Launcher.SessionID], the browser
+// should be launched with the [Launcher.ReattachGrace]. If the manager has the [Manager.Auth], only the
+// same client can reattach, otherwise anyone who knows the session id can, so keep the id secret.
+func (l *Launcher) Reattach(id string) *Launcher {
+	l.mustManaged()
+	l.sessionID = id
+	return l
+}
+
+// SessionID of the remote browser, it's set by the [Launcher.Client] if the [Launcher.ReattachGrace] is enabled.
+func (l *Launcher) SessionID() string {
+	return l.sessionID
+}
+
 // JSON serialization.
 func (l *Launcher) JSON() []byte {
 	return utils.MustToJSONBytes(l)
@@ -74,21 +140,57 @@ func (l *Launcher) JSON() []byte {
 
 // MustClient similar to Launcher.Client.
 func (l *Launcher) MustClient() *cdp.Client {
-	u, h := l.ClientHeader()
-	return cdp.MustStartWithURL(l.ctx, u, h)
+	c, err := l.Client()
+	utils.E(err)
+	return c
 }
 
 // Client for launching browser remotely via the launcher.Manager.
+// Use [Launcher.ManagedClient] to transfer files with the remote host or to use the tethering.
 func (l *Launcher) Client() (*cdp.Client, error) {
+	c, err := l.ManagedClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Client, nil
+}
+
+// MustManagedClient similar to Launcher.ManagedClient.
+func (l *Launcher) MustManagedClient() *ManagedClient {
+	c, err := l.ManagedClient()
+	utils.E(err)
+	return c
+}
+
+// ManagedClient is similar to [Launcher.Client], but the returned client also implements the
+// rod.RemoteFiles and rod.Tetherable, use it with rod like:
+//
+//	rod.New().Client(l.MustManagedClient()).MustConnect()
+func (l *Launcher) ManagedClient() (*ManagedClient, error) {
 	u, h := l.ClientHeader()
-	return cdp.StartWithURL(l.ctx, u, h)
+
+	ws := &cdp.WebSocket{}
+	err := ws.Connect(l.ctx, u, h)
+	if err != nil {
+		return nil, err
+	}
+
+	id := ws.ResponseHeader.Get(SessionHeaderName)
+	if l.Has(flags.ReattachGrace) {
+		l.sessionID = id
+	}
+
+	return newManagedClient(cdp.New().Start(ws), u, h, id), nil
 }
 
 // ClientHeader for launching browser remotely via the launcher.Manager.
 func (l *Launcher) ClientHeader() (string, http.Header) {
 	l.mustManaged()
-	header := http.Header{}
+	header := l.managedHeader.Clone()
 	header.Add(string(HeaderName), utils.MustToJSON(l))
+	if l.sessionID != "" {
+		header.Set(SessionHeaderName, l.sessionID)
+	}
 	return l.serviceURL, header
 }
 
@@ -124,6 +226,57 @@ type Manager struct {
 	// to launch the browser.
 	// Such as use it to filter malicious values of Launcher.UserDataDir, Launcher.Bin, or Launcher.WorkingDir.
 	BeforeLaunch func(*Launcher, http.ResponseWriter, *http.Request)
+
+	// Auth authenticates each request and returns the name of the client, such as [BearerAuth] or [CertAuth].
+	// The name is used by the ClientQuota and the logs. If it's nil, all requests are allowed and the name
+	// is the remote IP.
+	Auth func(*http.Request) (client string, err error)
+
+	// MaxSessions is the max number of the browsers running at the same time, 0 means unlimited.
+	// The launch requests beyond it will wait in a FIFO queue.
+	MaxSessions int
+
+	// QueueTimeout is how long a launch request can wait in the queue, 0 means until the client gives up.
+	QueueTimeout time.Duration
+
+	// ClientQuota is the max number of the sessions of each client, including the queued ones, 0 means unlimited.
+	ClientQuota int
+
+	// IdleTimeout kills the browser if no message is proxied for the duration, 0 means disabled.
+	IdleTimeout time.Duration
+
+	// MaxDuration kills the browser after the duration since the launch, 0 means disabled.
+	MaxDuration time.Duration
+
+	// AllowedFlags the clients can set, if it's nil all flags are allowed.
+	// The flags returned by the Defaults are always allowed, use the BeforeLaunch to check their values.
+	AllowedFlags []flags.Flag
+
+	// PoolSize is the number of the browsers to launch in advance, check [Manager.StartPool].
+	// The pre-launched browsers are not counted by the MaxSessions.
+	PoolSize int
+
+	// PoolLauncher returns the Launcher for the pre-launched browsers, the default is [New].
+	PoolLauncher func() *Launcher
+
+	// MaxReattachGrace is the max duration to keep a browser alive after its client disconnects,
+	// 0 means the reattach is disabled. Check [Launcher.ReattachGrace].
+	// The browser still counts for the MaxSessions and ClientQuota while it waits for the reattach.
+	MaxReattachGrace time.Duration
+
+	lock     sync.Mutex
+	sessions int
+	queue    []chan struct{}
+	clients  map[string]int
+
+	pool       []*pooledBrowser
+	poolCancel func()
+	poolDone   chan struct{}
+	poolRefill chan struct{}
+	poolHits   int64
+	poolMisses int64
+
+	running map[string]*managerSession
 }
 
 // NewManager instance.
@@ -134,7 +287,8 @@ func NewManager() *Manager {
 			p, _ := os.Getwd()
 			return p
 		}(),
-		flags.UserDataDir: DefaultUserDataDirPrefix,
+		flags.UserDataDir:         DefaultUserDataDirPrefix,
+		flags.UserDataDirTemplate: DefaultUserDataDirTemplatePrefix,
 	}
 
 	return &Manager{
@@ -143,7 +297,7 @@ func NewManager() *Manager {
 		BeforeLaunch: func(l *Launcher, w http.ResponseWriter, _ *http.Request) {
 			for f, allowed := range allowedPath {
 				p := l.Get(f)
-				if p != "" && !strings.HasPrefix(p, allowed) {
+				if p != "" && !isSubPath(p, allowed) {
 					b := []byte(fmt.Sprintf("[rod-manager] not allowed %s path: %s (use --allow-all to disable the protection)", f, p))
 					w.Header().Add("Content-Length", fmt.Sprintf("%d", len(b)))
 					w.WriteHeader(http.StatusBadRequest)
@@ -156,17 +310,53 @@ func NewManager() *Manager {
 	}
 }
 
+// isSubPath returns true if the cleaned absolute path of p is the root or inside the root, so ".." can't escape it.
+func isSubPath(p, root string) bool {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return false
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, err := m.authenticate(r)
+	if err != nil {
+		m.reject(w, http.StatusUnauthorized, "unauthorized", err)
+		return
+	}
+
+	id := r.Header.Get(SessionHeaderName)
+
 	if r.Header.Get("Upgrade") == "websocket" {
-		m.launch(w, r)
+		if connectionID, ok := strings.CutPrefix(r.URL.Path, "/tether/"); ok && id != "" {
+			m.serveTether(w, r, client, id, connectionID)
+			return
+		}
+		if id != "" {
+			m.reattach(w, r, client, id)
+			return
+		}
+		m.launch(w, r, client)
+		return
+	}
+
+	if id != "" {
+		m.serveFiles(w, r, client, id)
 		return
 	}
 
 	l := m.Defaults(w, r)
+	w.Header().Set(CapacityHeaderName, utils.MustToJSON(m.Capacity()))
 	utils.E(w.Write(l.JSON()))
 }
 
-func (m *Manager) launch(w http.ResponseWriter, r *http.Request) {
+func (m *Manager) launch(w http.ResponseWriter, r *http.Request, client string) {
 	l := New()
 
 	options := r.Header.Get(string(HeaderName))
@@ -177,35 +367,93 @@ func (m *Manager) launch(w http.ResponseWriter, r *http.Request) {
 
 	m.BeforeLaunch(l, w, r)
 
-	kill := l.Has(flags.Leakless)
+	if err := l.strictFlags(); err != nil {
+		m.reject(w, http.StatusBadRequest, "flags", err)
+		return
+	}
 
-	// Always enable leakless so that if the Manager process crashes
-	// all the managed browsers will be killed.
-	u := l.Leakless(true).MustLaunch()
-	defer m.cleanup(l, kill)
+	if err := m.checkFlags(l, w, r); err != nil {
+		m.reject(w, http.StatusForbidden, "flags", err)
+		return
+	}
+
+	if err := m.acquire(r.Context(), client); err != nil {
+		switch {
+		case errors.Is(err, ErrQuotaExceeded):
+			m.reject(w, http.StatusTooManyRequests, "quota", err)
+		case errors.Is(err, ErrQueueTimeout):
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(m.QueueTimeout.Seconds())+1))
+			m.reject(w, http.StatusServiceUnavailable, "queue_timeout", err)
+		}
+		return
+	}
+	defer m.release(client)
+
+	kill := l.Has(flags.Leakless)
+	keep := l.Has(flags.KeepUserDataDir)
+
+	b, u := l, ""
+	if pooled := m.takePooled(l); pooled != nil {
+		b, u = pooled.launcher, pooled.u
+		m.Logger.Println("Pool hit", client)
+	} else {
+		// Always enable leakless so that if the Manager process crashes
+		// all the managed browsers will be killed.
+		u = l.Leakless(true).MustLaunch()
+	}
+	defer m.cleanup(b, kill, keep, l.Get(flags.UserDataDir))
 
 	parsedURL, err := url.Parse(u)
 	utils.E(err)
 
-	m.Logger.Println("Launch", u, options)
-	defer m.Logger.Println("Close", u)
+	m.Logger.Println("Launch", client, u, options)
+	defer m.Logger.Println("Close", client, u)
+
+	s := newManagerSession(b, w)
+	s.client, s.remoteAddr, s.flags, s.u, s.pooled = client, r.RemoteAddr, l.Flags, u, b != l
+	s.grace = m.reattachGrace(l)
+	s.dir = newSessionDir()
+	defer func() { _ = os.RemoveAll(s.dir) }()
+	m.addSession(s)
+	defer m.removeSession(s.id)
+	defer close(s.done)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go m.watch(ctx, s)
+
+	metrics.ManagerSessions.Inc("")
+	defer metrics.ManagerSessions.Dec("")
+	defer metrics.ManagerSessionDuration.Since("", time.Now())
 
 	parsedWS, err := url.Parse(u)
 	utils.E(err)
 	parsedURL.Path = parsedWS.Path
 
-	httputil.NewSingleHostReverseProxy(toHTTP(*parsedURL)).ServeHTTP(w, r)
+	m.serve(s, httputil.NewSingleHostReverseProxy(toHTTP(*parsedURL)), r)
 }
 
-func (m *Manager) cleanup(l *Launcher, kill bool) {
+// cleanup the browser after the session. If the user data dir is kept and the browser is
+// pre-launched, its user data dir will be moved to the dir the client requested.
+func (m *Manager) cleanup(l *Launcher, kill, keep bool, dir string) {
 	if kill {
 		l.Kill()
 		m.Logger.Println("Killed PID:", l.PID())
 	}
 
-	if !l.Has(flags.KeepUserDataDir) {
+	if !keep {
 		l.Cleanup()
 		dir := l.Get(flags.UserDataDir)
 		m.Logger.Println("Removed", dir)
+		return
+	}
+
+	if from := l.Get(flags.UserDataDir); dir != "" && from != dir {
+		_ = l.Wait()
+		if err := os.Rename(from, dir); err != nil {
+			m.Logger.Println("Failed to move the user data dir:", err)
+			return
+		}
+		m.Logger.Println("Moved", from, "to", dir)
 	}
 }
@@ -0,0 +1,291 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/proto"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// SessionInfo of a running session of the [Manager].
+type SessionInfo struct {
+	ID         string                  `json:"id"`
+	Client     string                  `json:"client"`
+	RemoteAddr string                  `json:"remoteAddr"`
+	Flags      map[flags.Flag][]string `json:"flags"`
+	PID        int                     `json:"pid"`
+	Start      time.Time               `json:"start"`
+
+	// Pooled is true if the browser is pre-launched, check [Manager.StartPool].
+	Pooled bool `json:"pooled"`
+
+	// Sent is the bytes proxied from the browser to the client.
+	Sent int64 `json:"sent"`
+
+	// Received is the bytes proxied from the client to the browser.
+	Received int64 `json:"received"`
+}
+
+// Sessions returns the running sessions sorted by the start time.
+func (m *Manager) Sessions() []*SessionInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	list := []*SessionInfo{}
+	for _, s := range m.running {
+		list = append(list, &SessionInfo{
+			ID:         s.id,
+			Client:     s.client,
+			RemoteAddr: s.remoteAddr,
+			Flags:      s.flags,
+			PID:        s.launcher.PID(),
+			Start:      s.start,
+			Pooled:     s.pooled,
+			Sent:       atomic.LoadInt64(&s.sent),
+			Received:   atomic.LoadInt64(&s.received),
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+
+	return list
+}
+
+// KillSession kills the browser of the session and closes the connection of its client.
+func (m *Manager) KillSession(id string) error {
+	s, err := m.session(id)
+	if err != nil {
+		return err
+	}
+
+	metrics.ManagerKills.Inc("admin")
+	m.Logger.Println("Kill", "admin", s.launcher.PID())
+	s.kill()
+
+	return nil
+}
+
+// SessionLog returns the recent stdout and stderr of the browser of the session, check [Launcher.Output].
+func (m *Manager) SessionLog(id string) (string, error) {
+	s, err := m.session(id)
+	if err != nil {
+		return "", err
+	}
+	return s.launcher.Output(), nil
+}
+
+// SessionPages returns the pages of the session's browser.
+func (m *Manager) SessionPages(ctx context.Context, id string) ([]*proto.TargetTargetInfo, error) {
+	list := []*proto.TargetTargetInfo{}
+
+	err := m.withBrowser(ctx, id, func(c *adminClient) error {
+		res, err := proto.TargetGetTargets{}.Call(c)
+		if err != nil {
+			return err
+		}
+		for _, info := range res.TargetInfos {
+			if info.Type == proto.TargetTargetInfoTypePage {
+				list = append(list, info)
+			}
+		}
+		return nil
+	})
+
+	return list, err
+}
+
+// SessionScreenshot takes a png screenshot of the page of the session's browser.
+// If the targetID is empty, the first page will be used.
+func (m *Manager) SessionScreenshot(ctx context.Context, id string, targetID proto.TargetTargetID) ([]byte, error) {
+	var img []byte
+
+	err := m.withBrowser(ctx, id, func(c *adminClient) error {
+		if targetID == "" {
+			res, err := proto.TargetGetTargets{}.Call(c)
+			if err != nil {
+				return err
+			}
+			for _, info := range res.TargetInfos {
+				if info.Type == proto.TargetTargetInfoTypePage {
+					targetID = info.TargetID
+					break
+				}
+			}
+			if targetID == "" {
+				return fmt.Errorf("no page in session %s", id)
+			}
+		}
+
+		attached, err := proto.TargetAttachToTarget{TargetID: targetID, Flatten: true}.Call(c)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = proto.TargetDetachFromTarget{SessionID: attached.SessionID}.Call(c) }()
+
+		res, err := proto.PageCaptureScreenshot{}.Call(&adminClient{c.Client, c.ctx, attached.SessionID})
+		if err != nil {
+			return err
+		}
+		img = res.Data
+		return nil
+	})
+
+	return img, err
+}
+
+// withBrowser opens a new cdp connection to the browser of the session.
+func (m *Manager) withBrowser(ctx context.Context, id string, fn func(*adminClient) error) error {
+	s, err := m.session(id)
+	if err != nil {
+		return err
+	}
+
+	ws := &cdp.WebSocket{}
+	err = ws.Connect(ctx, s.u, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = ws.Close() }()
+
+	return fn(&adminClient{cdp.New().Start(ws), ctx, ""})
+}
+
+func (m *Manager) session(id string) (*managerSession, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s, has := m.running[id]
+	if !has {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return s, nil
+}
+
+// clientSession returns the session of the id if the client owns it.
+// With the [Manager.Auth] only the client that launched the session owns it,
+// without it the client is the remote IP that may change after a reconnect, so the session id alone is enough.
+func (m *Manager) clientSession(client, id string) (*managerSession, error) {
+	s, err := m.session(id)
+	if err == nil && m.Auth != nil && s.client != client {
+		// don't tell the client whether the session of others exists
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	return s, err
+}
+
+func (m *Manager) addSession(s *managerSession) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.running == nil {
+		m.running = map[string]*managerSession{}
+	}
+	m.running[s.id] = s
+}
+
+func (m *Manager) removeSession(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.running, id)
+}
+
+// adminClient calls the cdp methods with the context and the session.
+type adminClient struct {
+	*cdp.Client
+	ctx       context.Context
+	sessionID proto.TargetSessionID
+}
+
+func (c *adminClient) GetContext() context.Context { return c.ctx }
+
+func (c *adminClient) GetSessionID() proto.TargetSessionID { return c.sessionID }
+
+// AdminHandler serves the JSON admin API of the manager:
+//
+//	GET  /sessions                     list the running sessions, check [Manager.Sessions]
+//	POST /sessions/{id}/kill           kill a session, check [Manager.KillSession]
+//	GET  /sessions/{id}/log            get the output of the browser, check [Manager.SessionLog]
+//	GET  /sessions/{id}/pages          list the pages, check [Manager.SessionPages]
+//	GET  /sessions/{id}/screenshot     take a png screenshot, the optional query "page" is the target id
+//	GET  /pool                         get the stats of the pre-launched browsers, check [Manager.PoolStats]
+//
+// The auth is the same as [Manager.Auth], if it's nil all requests are allowed.
+// Use http.StripPrefix to mount it under a path, such as "/admin/".
+func (m *Manager) AdminHandler(auth func(*http.Request) (string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth != nil {
+			if _, err := auth(r); err != nil {
+				http.Error(w, "[rod-manager] "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		switch {
+		case len(path) == 1 && path[0] == "sessions" && r.Method == http.MethodGet:
+			adminJSON(w, m.Sessions(), nil)
+
+		case len(path) == 1 && path[0] == "pool" && r.Method == http.MethodGet:
+			adminJSON(w, m.PoolStats(), nil)
+
+		case len(path) == 3 && path[0] == "sessions" && path[2] == "kill" && r.Method == http.MethodPost:
+			adminJSON(w, map[string]string{"id": path[1]}, m.KillSession(path[1]))
+
+		case len(path) == 3 && path[0] == "sessions" && path[2] == "log" && r.Method == http.MethodGet:
+			log, err := m.SessionLog(path[1])
+			if adminErr(w, err) {
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			utils.E(w.Write([]byte(log)))
+
+		case len(path) == 3 && path[0] == "sessions" && path[2] == "pages" && r.Method == http.MethodGet:
+			list, err := m.SessionPages(r.Context(), path[1])
+			adminJSON(w, list, err)
+
+		case len(path) == 3 && path[0] == "sessions" && path[2] == "screenshot" && r.Method == http.MethodGet:
+			img, err := m.SessionScreenshot(r.Context(), path[1], proto.TargetTargetID(r.URL.Query().Get("page")))
+			if adminErr(w, err) {
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			utils.E(w.Write(img))
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func adminJSON(w http.ResponseWriter, v interface{}, err error) {
+	if adminErr(w, err) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	utils.E(w.Write(utils.MustToJSONBytes(v)))
+}
+
+func adminErr(w http.ResponseWriter, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	code := http.StatusInternalServerError
+	if errors.Is(err, ErrSessionNotFound) {
+		code = http.StatusNotFound
+	}
+	http.Error(w, "[rod-manager] "+err.Error(), code)
+	return true
+}
@@ -0,0 +1,150 @@
+package launcher
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// ManagerConfig is the policy of a [Manager], check [LoadManagerConfig] for the file format.
+type ManagerConfig struct {
+	// Tokens is the map of the client name to its bearer token, check [BearerAuth].
+	Tokens map[string]string
+
+	MaxSessions  int
+	QueueTimeout time.Duration
+	ClientQuota  int
+	IdleTimeout  time.Duration
+	MaxDuration  time.Duration
+	AllowedFlags []flags.Flag
+
+	MaxReattachGrace time.Duration
+}
+
+// MustLoadManagerConfig is similar to [LoadManagerConfig].
+func MustLoadManagerConfig(path string) *ManagerConfig {
+	c, err := LoadManagerConfig(path)
+	utils.E(err)
+	return c
+}
+
+// LoadManagerConfig from a YAML or JSON file, the format is decided by the file extension. Such as:
+//
+//	tokens:
+//	  team-a: ${TEAM_A_TOKEN}
+//	  team-b: ${TEAM_B_TOKEN}
+//	max-sessions: 10
+//	queue-timeout: 30s
+//	client-quota: 3
+//	idle-timeout: 5m
+//	max-duration: 1h
+//	allowed-flags: [window-size, lang, proxy-server]
+//	max-reattach-grace: 2m
+//
+// The env vars are expanded the same way as [Load].
+func LoadManagerConfig(path string) (*ManagerConfig, error) {
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := raw.(map[string]interface{})
+	if !ok && raw != nil {
+		return nil, fmt.Errorf("%s: the top level should be a map", path)
+	}
+
+	c := &ManagerConfig{}
+	for k, v := range m {
+		switch k {
+		case "tokens":
+			err = c.decodeTokens(v)
+		case "max-sessions":
+			c.MaxSessions, err = configInt(v)
+		case "queue-timeout":
+			c.QueueTimeout, err = configDuration(v)
+		case "client-quota":
+			c.ClientQuota, err = configInt(v)
+		case "idle-timeout":
+			c.IdleTimeout, err = configDuration(v)
+		case "max-duration":
+			c.MaxDuration, err = configDuration(v)
+		case "allowed-flags":
+			var list []string
+			list, err = profileList(v)
+			c.AllowedFlags = []flags.Flag{}
+			for _, f := range list {
+				c.AllowedFlags = append(c.AllowedFlags, flags.Flag(f).NormalizeFlag())
+			}
+		case "max-reattach-grace":
+			c.MaxReattachGrace, err = configDuration(v)
+		default:
+			err = errors.New("unknown field")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, k, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Apply the config to the manager. The [Manager.Auth] is set to [BearerAuth] if there are tokens.
+func (c *ManagerConfig) Apply(m *Manager) {
+	if len(c.Tokens) > 0 {
+		m.Auth = BearerAuth(c.Tokens)
+	}
+	m.MaxSessions = c.MaxSessions
+	m.QueueTimeout = c.QueueTimeout
+	m.ClientQuota = c.ClientQuota
+	m.IdleTimeout = c.IdleTimeout
+	m.MaxDuration = c.MaxDuration
+	m.AllowedFlags = c.AllowedFlags
+	m.MaxReattachGrace = c.MaxReattachGrace
+}
+
+func (c *ManagerConfig) decodeTokens(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New("should be a map")
+	}
+
+	c.Tokens = map[string]string{}
+	for client, v := range m {
+		token, err := profileStr(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", client, err)
+		}
+		if token == "" {
+			return fmt.Errorf("%s: empty token", client)
+		}
+		c.Tokens[client] = token
+	}
+
+	return nil
+}
+
+func configInt(v interface{}) (int, error) {
+	s, err := profileStr(v)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+// configDuration accepts the duration string such as "1m30s" or a number of seconds.
+func configDuration(v interface{}) (time.Duration, error) {
+	if n, ok := v.(float64); ok {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+
+	s, err := profileStr(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.ParseDuration(s)
+}
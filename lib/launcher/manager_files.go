@@ -0,0 +1,98 @@
+package launcher
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// serveFiles serves the files in the working dir of the session:
+//
+//	GET /files         get the path of the dir as {"dir": "/tmp/rod-session-xxx"}
+//	GET /files/{name}  download a file
+//	PUT /files/{name}  upload a file, it returns its path as {"path": "/tmp/rod-session-xxx/name"}
+//
+// The session id is in the [SessionHeaderName] header, only the client that owns the session can access it, check [Manager.clientSession].
+func (m *Manager) serveFiles(w http.ResponseWriter, r *http.Request, client, id string) {
+	s, err := m.clientSession(client, id)
+	if adminErr(w, err) {
+		return
+	}
+
+	name, ok := strings.CutPrefix(path.Clean("/"+r.URL.Path), "/files")
+	if !ok || (name != "" && name[0] != '/') {
+		http.NotFound(w, r)
+		return
+	}
+	name = strings.TrimPrefix(name, "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "[rod-manager] method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		adminJSON(w, map[string]string{"dir": s.dir}, nil)
+		return
+	}
+
+	p := filepath.FromSlash(name)
+	if !filepath.IsLocal(p) {
+		http.Error(w, "[rod-manager] invalid file name: "+name, http.StatusBadRequest)
+		return
+	}
+	p = filepath.Join(s.dir, p)
+
+	switch r.Method {
+	case http.MethodGet:
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			http.Error(w, "[rod-manager] file not found: "+name, http.StatusNotFound)
+			return
+		}
+		if adminErr(w, err) {
+			return
+		}
+		defer func() { _ = f.Close() }()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = io.Copy(w, f)
+
+	case http.MethodPut:
+		m.Logger.Println("Upload", client, p)
+		adminJSON(w, map[string]string{"path": p}, saveFile(p, r.Body))
+
+	default:
+		http.Error(w, "[rod-manager] method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func saveFile(p string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// newSessionDir creates the working dir of a session.
+func newSessionDir() string {
+	dir, err := os.MkdirTemp("", "rod-session-")
+	utils.E(err)
+	return dir
+}
@@ -0,0 +1,235 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// PoolStats of the pre-launched browsers of the [Manager].
+type PoolStats struct {
+	// Size is the target number of the pre-launched browsers.
+	Size int `json:"size"`
+
+	// Idle is the number of the pre-launched browsers ready to use.
+	Idle int `json:"idle"`
+
+	// Hits is the number of the sessions that used a pre-launched browser.
+	Hits int64 `json:"hits"`
+
+	// Misses is the number of the sessions that launched a new browser.
+	Misses int64 `json:"misses"`
+}
+
+// HitRate is the ratio of the hits to all the sessions.
+func (s PoolStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type pooledBrowser struct {
+	launcher *Launcher
+	u        string
+}
+
+// StartPool starts to keep [Manager.PoolSize] browsers launched in the background.
+// A launch request uses an idle one if its flags are the same as the [Manager.PoolLauncher]'s,
+// except the flags that don't affect the browser, such as a fresh user data dir.
+// A used browser is never reused, it's killed and cleaned up the same way as a new launched one,
+// then the pool launches another one to refill.
+func (m *Manager) StartPool() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.poolCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.poolCancel = cancel
+	m.poolDone = make(chan struct{})
+	m.poolRefill = make(chan struct{}, 1)
+
+	go m.fillPool(ctx)
+}
+
+// StopPool stops the refilling and kills the idle pre-launched browsers.
+func (m *Manager) StopPool() {
+	m.lock.Lock()
+	cancel, done := m.poolCancel, m.poolDone
+	m.poolCancel = nil
+	m.lock.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+
+	m.lock.Lock()
+	idle := m.pool
+	m.pool = nil
+	m.lock.Unlock()
+
+	for _, b := range idle {
+		m.cleanup(b.launcher, true, false, "")
+	}
+}
+
+// PoolStats returns the stats of the pre-launched browsers.
+func (m *Manager) PoolStats() PoolStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return PoolStats{
+		Size:   m.PoolSize,
+		Idle:   len(m.pool),
+		Hits:   atomic.LoadInt64(&m.poolHits),
+		Misses: atomic.LoadInt64(&m.poolMisses),
+	}
+}
+
+func (m *Manager) fillPool(ctx context.Context) {
+	defer close(m.poolDone)
+
+	sleeper := utils.BackoffSleeper(time.Second, 30*time.Second, nil)
+
+	for {
+		m.lock.Lock()
+		full := len(m.pool) >= m.PoolSize
+		refill := m.poolRefill
+		m.lock.Unlock()
+
+		if full {
+			select {
+			case <-ctx.Done():
+				return
+			case <-refill:
+			}
+			continue
+		}
+
+		l := m.newPoolLauncher()
+		u, err := l.Context(ctx).Leakless(true).Launch()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.Logger.Println("Pool launch error:", err)
+			if sleeper(ctx) != nil {
+				return
+			}
+			continue
+		}
+
+		sleeper = utils.BackoffSleeper(time.Second, 30*time.Second, nil)
+
+		m.lock.Lock()
+		stopped := ctx.Err() != nil
+		if !stopped {
+			m.pool = append(m.pool, &pooledBrowser{l, u})
+		}
+		m.lock.Unlock()
+
+		if stopped {
+			m.cleanup(l, true, false, "")
+			return
+		}
+
+		m.Logger.Println("Pool launched", u)
+	}
+}
+
+func (m *Manager) newPoolLauncher() *Launcher {
+	if m.PoolLauncher != nil {
+		return m.PoolLauncher()
+	}
+	return New()
+}
+
+// takePooled returns an idle pre-launched browser that is compatible with l, or nil if there's none.
+func (m *Manager) takePooled(l *Launcher) *pooledBrowser {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.poolCancel == nil {
+		return nil
+	}
+
+	for i := 0; i < len(m.pool); i++ {
+		b := m.pool[i]
+
+		select {
+		case <-b.launcher.exit:
+			// the browser exited while idle
+			m.pool = append(m.pool[:i], m.pool[i+1:]...)
+			i--
+			go m.cleanup(b.launcher, false, false, "")
+			continue
+		default:
+		}
+
+		if poolCompatible(b.launcher, l) {
+			m.pool = append(m.pool[:i], m.pool[i+1:]...)
+			m.triggerRefill()
+			atomic.AddInt64(&m.poolHits, 1)
+			metrics.ManagerPool.Inc("hit")
+			return b
+		}
+	}
+
+	m.triggerRefill()
+	atomic.AddInt64(&m.poolMisses, 1)
+	metrics.ManagerPool.Inc("miss")
+	return nil
+}
+
+func (m *Manager) triggerRefill() {
+	select {
+	case m.poolRefill <- struct{}{}:
+	default:
+	}
+}
+
+// poolIgnoredFlags don't affect the launched browser.
+var poolIgnoredFlags = []flags.Flag{flags.UserDataDir, flags.Leakless, flags.KeepUserDataDir, flags.ReattachGrace}
+
+// poolCompatible checks if the pooled browser can serve the launch request of l.
+func poolCompatible(pooled, l *Launcher) bool {
+	// only a fresh temp dir is the same as the dir of the pooled browser
+	dir := l.Get(flags.UserDataDir)
+	if dir == "" || !strings.HasPrefix(filepath.Clean(dir), filepath.Clean(DefaultUserDataDirPrefix)) {
+		return false
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return false
+	}
+
+	return reflect.DeepEqual(poolFlags(pooled), poolFlags(l))
+}
+
+func poolFlags(l *Launcher) map[flags.Flag][]string {
+	list := map[flags.Flag][]string{}
+	for f, v := range l.Flags {
+		if slices.Contains(poolIgnoredFlags, f) {
+			continue
+		}
+		if len(v) == 0 {
+			v = nil
+		}
+		list[f] = v
+	}
+	return list
+}
@@ -0,0 +1,111 @@
+package launcher
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
+)
+
+// managerAttach is a reattach request waiting for the session to proxy it.
+type managerAttach struct {
+	w    http.ResponseWriter
+	r    *http.Request
+	done chan struct{}
+}
+
+// reattachGrace returns the grace period the client asked for, capped by the MaxReattachGrace.
+func (m *Manager) reattachGrace(l *Launcher) time.Duration {
+	n, err := strconv.Atoi(l.Get(flags.ReattachGrace))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return min(time.Duration(n)*time.Second, m.MaxReattachGrace)
+}
+
+// serve proxies the client to the browser of the session, the session id is returned to the client
+// via the [SessionHeaderName] header. If the session has a grace period,
+// after the client disconnects it keeps proxying the clients that reattach to it.
+func (m *Manager) serve(s *managerSession, proxy http.Handler, r *http.Request) {
+	var done chan struct{}
+
+	for {
+		s.Header().Set(SessionHeaderName, s.id)
+
+		proxy.ServeHTTP(s, r)
+
+		if done != nil {
+			close(done)
+		}
+
+		a := m.detach(s)
+		if a == nil {
+			return
+		}
+
+		s.ResponseWriter, r, done = a.w, a.r, a.done
+	}
+}
+
+// detach waits for a client to reattach to the session within the grace period.
+func (m *Manager) detach(s *managerSession) *managerAttach {
+	if s.grace <= 0 {
+		return nil
+	}
+
+	select {
+	case <-s.launcher.exit:
+		return nil
+	default:
+	}
+
+	m.Logger.Println("Detach", s.client, s.id, s.grace)
+
+	t := time.NewTimer(s.grace)
+	defer t.Stop()
+
+	select {
+	case a := <-s.attach:
+		metrics.ManagerReattaches.Inc("ok")
+		m.Logger.Println("Reattach", s.client, s.id)
+		return a
+	case <-t.C:
+		metrics.ManagerReattaches.Inc("timeout")
+		m.Logger.Println("Reattach timeout", s.client, s.id)
+		return nil
+	case <-s.launcher.exit:
+		return nil
+	}
+}
+
+// reattach hands the websocket request over to the session of the id, then waits until it's proxied.
+// Only the client that owns the session can reattach to it, check [Manager.clientSession].
+func (m *Manager) reattach(w http.ResponseWriter, r *http.Request, client, id string) {
+	s, err := m.clientSession(client, id)
+	if err == nil && s.grace <= 0 {
+		err = fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		m.reject(w, http.StatusNotFound, "reattach", err)
+		return
+	}
+
+	// the previous connection may be stale after a network hiccup, drop it to take over the session
+	s.closeConn()
+
+	a := &managerAttach{w, r, make(chan struct{})}
+
+	select {
+	case s.attach <- a:
+	case <-s.done:
+		m.reject(w, http.StatusNotFound, "reattach", fmt.Errorf("%w: %s", ErrSessionNotFound, id))
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	<-a.done
+}
@@ -0,0 +1,384 @@
+package launcher
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// BearerAuth for the [Manager.Auth], the tokens is the map of the client name to its token.
+// The client should send the header "Authorization: Bearer <token>", check [NewManaged],
+// or the query "access_token" of the url.
+func BearerAuth(tokens map[string]string) func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			// such as for the links of the status page
+			token = r.URL.Query().Get("access_token")
+		}
+		if token == "" {
+			return "", fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+		}
+
+		for client, t := range tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return client, nil
+			}
+		}
+
+		return "", fmt.Errorf("%w: invalid bearer token", ErrUnauthorized)
+	}
+}
+
+// CertAuth for the [Manager.Auth], it uses the common name of the verified client certificate as the client name.
+// The http server should verify the client certificates, such as:
+//
+//	srv := &http.Server{Handler: m, TLSConfig: &tls.Config{
+//		ClientCAs:  pool,
+//		ClientAuth: tls.RequireAndVerifyClientCert,
+//	}}
+func CertAuth() func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return "", fmt.Errorf("%w: missing verified client certificate", ErrUnauthorized)
+		}
+		return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+	}
+}
+
+// ForwardedAuth for the [Manager.Auth] behind the [Balancer]. The auth authenticates the request as usual,
+// if the client is one of the proxies, such as the client name of the balancer's token, the name of the client
+// forwarded via the [ClientHeaderName] header is used instead, prefixed with the proxy name, such as "balancer/alice".
+// So the ClientQuota and the ownership of the sessions apply to each client of the balancer.
+// The header is ignored if the request isn't from the proxies.
+func ForwardedAuth(auth func(*http.Request) (string, error), proxies ...string) func(*http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		client, err := auth(r)
+		if err != nil {
+			return "", err
+		}
+
+		if name := r.Header.Get(ClientHeaderName); name != "" && slices.Contains(proxies, client) {
+			return client + "/" + name, nil
+		}
+		return client, nil
+	}
+}
+
+func (m *Manager) authenticate(r *http.Request) (string, error) {
+	if m.Auth == nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr, nil //nolint: nilerr
+		}
+		return host, nil
+	}
+	return m.Auth(r)
+}
+
+func (m *Manager) reject(w http.ResponseWriter, code int, reason string, err error) {
+	metrics.ManagerRejections.Inc(reason)
+	m.Logger.Println("Reject", reason, err)
+	http.Error(w, "[rod-manager] "+err.Error(), code)
+}
+
+// managedFlags are the flags that the clients need to control the sessions.
+var managedFlags = []flags.Flag{
+	flags.Arguments,
+	flags.Leakless,
+	flags.KeepUserDataDir,
+	flags.ReattachGrace,
+	"disable-http2", // set by MustNewManaged
+}
+
+func (m *Manager) checkFlags(l *Launcher, w http.ResponseWriter, r *http.Request) error {
+	if m.AllowedFlags == nil {
+		return nil
+	}
+
+	defaults := m.Defaults(w, r)
+
+	for f := range l.Flags {
+		if _, has := defaults.Flags[f]; has {
+			continue
+		}
+		if !slices.Contains(m.AllowedFlags, f) && !slices.Contains(managedFlags, f) {
+			return fmt.Errorf("%w: --%s", ErrFlagNotAllowed, f)
+		}
+	}
+
+	return nil
+}
+
+// ManagerCapacity is the load of a [Manager], check [Balancer].
+type ManagerCapacity struct {
+	// Sessions is the number of the running sessions.
+	Sessions int `json:"sessions"`
+
+	// Queued is the number of the launch requests waiting for a free slot.
+	Queued int `json:"queued"`
+
+	// MaxSessions is the same as the [Manager.MaxSessions], 0 means unlimited.
+	MaxSessions int `json:"maxSessions"`
+}
+
+// Free returns the number of the free slots, -1 means unlimited.
+func (c ManagerCapacity) Free() int {
+	if c.MaxSessions <= 0 {
+		return -1
+	}
+	return max(c.MaxSessions-c.Sessions-c.Queued, 0)
+}
+
+// Capacity returns the current load of the manager.
+func (m *Manager) Capacity() ManagerCapacity {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return ManagerCapacity{
+		Sessions:    m.sessions,
+		Queued:      len(m.queue),
+		MaxSessions: m.MaxSessions,
+	}
+}
+
+// acquire a slot to launch a browser for the client, it waits in the queue if there's no free slot.
+func (m *Manager) acquire(ctx context.Context, client string) error {
+	m.lock.Lock()
+
+	if m.clients == nil {
+		m.clients = map[string]int{}
+	}
+
+	if m.ClientQuota > 0 && m.clients[client] >= m.ClientQuota {
+		m.lock.Unlock()
+		return fmt.Errorf("%w: client %s has %d sessions", ErrQuotaExceeded, client, m.ClientQuota)
+	}
+	m.clients[client]++
+
+	if m.MaxSessions <= 0 || (m.sessions < m.MaxSessions && len(m.queue) == 0) {
+		m.sessions++
+		m.lock.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	m.queue = append(m.queue, ready)
+	m.lock.Unlock()
+
+	metrics.ManagerQueued.Inc("")
+	defer metrics.ManagerQueued.Dec("")
+
+	var timeout <-chan time.Time
+	if m.QueueTimeout > 0 {
+		t := time.NewTimer(m.QueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = fmt.Errorf("%w after %s", ErrQueueTimeout, m.QueueTimeout)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	select {
+	case <-ready:
+		// the slot is granted at the same time, give it to the next one
+		m.sessions--
+		m.next()
+	default:
+		for i, c := range m.queue {
+			if c == ready {
+				m.queue = append(m.queue[:i], m.queue[i+1:]...)
+				break
+			}
+		}
+	}
+	m.leave(client)
+
+	return err
+}
+
+func (m *Manager) release(client string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.sessions--
+	m.leave(client)
+	m.next()
+}
+
+func (m *Manager) leave(client string) {
+	m.clients[client]--
+	if m.clients[client] <= 0 {
+		delete(m.clients, client)
+	}
+}
+
+// next grants the free slots to the queue in FIFO order.
+func (m *Manager) next() {
+	for len(m.queue) > 0 && (m.MaxSessions <= 0 || m.sessions < m.MaxSessions) {
+		close(m.queue[0])
+		m.queue = m.queue[1:]
+		m.sessions++
+	}
+}
+
+// watch kills the browser of the session when it's idle for too long or runs over the max duration.
+func (m *Manager) watch(ctx context.Context, s *managerSession) {
+	if m.IdleTimeout <= 0 && m.MaxDuration <= 0 {
+		return
+	}
+
+	for {
+		now := time.Now()
+		var deadline time.Time
+		reason := ""
+
+		if m.MaxDuration > 0 {
+			deadline, reason = s.start.Add(m.MaxDuration), "max_duration"
+		}
+		if m.IdleTimeout > 0 {
+			if d := s.lastActive().Add(m.IdleTimeout); reason == "" || d.Before(deadline) {
+				deadline, reason = d, "idle"
+			}
+		}
+
+		if !deadline.After(now) {
+			metrics.ManagerKills.Inc(reason)
+			m.Logger.Println("Kill", reason, s.launcher.PID())
+			s.kill()
+			return
+		}
+
+		t := time.NewTimer(deadline.Sub(now))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// managerSession wraps the http.ResponseWriter of a launch request to track the proxied websocket.
+type managerSession struct {
+	http.ResponseWriter
+
+	id         string
+	client     string
+	remoteAddr string
+	flags      map[flags.Flag][]string
+	u          string
+	pooled     bool
+	dir        string
+
+	grace  time.Duration
+	attach chan *managerAttach
+	done   chan struct{}
+
+	launcher *Launcher
+	start    time.Time
+	active   int64 // unix nano of the last proxied message
+	sent     int64
+	received int64
+
+	lock    sync.Mutex
+	conn    net.Conn
+	tethers map[string]struct{} // the connection ids of the Tethering.accepted events
+}
+
+func newManagerSession(l *Launcher, w http.ResponseWriter) *managerSession {
+	now := time.Now()
+	return &managerSession{
+		ResponseWriter: w,
+		id:             utils.RandString(16),
+		attach:         make(chan *managerAttach),
+		tethers:        map[string]struct{}{},
+		done:           make(chan struct{}),
+		launcher:       l,
+		start:          now,
+		active:         now.UnixNano(),
+	}
+}
+
+// Hijack is called by the reverse proxy to take over the websocket connection of the client.
+func (s *managerSession) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(s.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.conn = &managerConn{Conn: conn, s: s, tether: &tetherScanner{s: s}}
+
+	return s.conn, rw, nil
+}
+
+// Unwrap is used by the http.ResponseController.
+func (s *managerSession) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func (s *managerSession) lastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.active))
+}
+
+func (s *managerSession) kill() {
+	s.launcher.Kill()
+	s.closeConn()
+}
+
+func (s *managerSession) closeConn() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+}
+
+type managerConn struct {
+	net.Conn
+	s      *managerSession
+	tether *tetherScanner
+}
+
+func (c *managerConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		atomic.AddInt64(&c.s.received, int64(n))
+		atomic.StoreInt64(&c.s.active, time.Now().UnixNano())
+	}
+	return n, err
+}
+
+func (c *managerConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.tether.scan(b[:n])
+		atomic.AddInt64(&c.s.sent, int64(n))
+		atomic.StoreInt64(&c.s.active, time.Now().UnixNano())
+	}
+	return n, err
+}
@@ -0,0 +1,261 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/mux"
+)
+
+// serveTether pipes the websocket of the client to the tethering socket of the browser of the session.
+// The connection id is the name of the socket, check [dialTether]. Only the connections that the browser
+// of the session has accepted can be opened, each of them once, check [tetherScanner].
+func (m *Manager) serveTether(w http.ResponseWriter, r *http.Request, client, id, connectionID string) {
+	s, err := m.clientSession(client, id)
+	if adminErr(w, err) {
+		return
+	}
+
+	if !s.takeTether(connectionID) {
+		m.reject(w, http.StatusNotFound, "tether", fmt.Errorf("%w: %s", ErrTetherNotFound, connectionID))
+		return
+	}
+
+	conn, err := dialTether(r.Context(), connectionID)
+	if err != nil {
+		http.Error(w, "[rod-manager] "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	ws, err := mux.Upgrade(w, r)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	m.Logger.Println("Tether", client, connectionID)
+	defer m.Logger.Println("Untether", client, connectionID)
+
+	pipe(&wsStream{ws: ws}, conn)
+}
+
+// dialTether connects to the tethering socket of the browser. The name of the socket is a loopback address,
+// such as "127.0.0.1:9222", or the name of an abstract unix socket on Linux.
+// Other addresses are refused so that the clients can't use the manager to reach other hosts.
+func dialTether(ctx context.Context, name string) (net.Conn, error) {
+	d := &net.Dialer{}
+
+	host, _, err := net.SplitHostPort(name)
+	if err != nil {
+		return d.DialContext(ctx, "unix", "@"+name)
+	}
+
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("tethering socket should be a loopback address: %s", name)
+	}
+
+	return d.DialContext(ctx, "tcp", name)
+}
+
+// takeTether removes the accepted connection id, it returns false if the id isn't accepted.
+func (s *managerSession) takeTether(connectionID string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, has := s.tethers[connectionID]
+	delete(s.tethers, connectionID)
+	return has
+}
+
+// tetherScanLimit is the max size of the messages to scan, the Tethering.accepted events are much smaller.
+const tetherScanLimit = 4 * 1024
+
+// tetherScanner scans the websocket frames that the browser sends to the client of the session for the
+// Tethering.accepted events, and records their connection ids in the session.
+// The frames may be split across the writes, so it keeps the state of the current frame.
+type tetherScanner struct {
+	s *managerSession
+
+	head    []byte // the partial header of the current frame
+	payload bool   // the header is complete, the payload is being read
+	remain  int64  // the size of the payload left
+	fin     bool
+	control bool
+	masked  bool
+	mask    [4]byte
+	pos     int // the position in the payload for the mask
+
+	msg  []byte // the payload of the current message
+	skip bool   // the current message is larger than the tetherScanLimit
+}
+
+func (t *tetherScanner) scan(p []byte) {
+	for len(p) > 0 {
+		if !t.payload {
+			t.head = append(t.head, p[0])
+			p = p[1:]
+			if t.parseHead() && t.remain == 0 {
+				t.endFrame()
+			}
+			continue
+		}
+
+		n := int(min(int64(len(p)), t.remain))
+		t.read(p[:n])
+		p = p[n:]
+		t.remain -= int64(n)
+		if t.remain == 0 {
+			t.endFrame()
+		}
+	}
+}
+
+// parseHead returns true if the header of the frame is complete.
+func (t *tetherScanner) parseHead() bool {
+	h := t.head
+	if len(h) < 2 {
+		return false
+	}
+
+	size := 2
+	switch h[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if h[1]&0x80 != 0 {
+		size += 4
+	}
+	if len(h) < size {
+		return false
+	}
+
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		n = int64(binary.BigEndian.Uint16(h[2:]))
+	case 127:
+		n = int64(binary.BigEndian.Uint64(h[2:]) & (1<<63 - 1))
+	}
+
+	t.fin, t.control = h[0]&0x80 != 0, h[0]&0x08 != 0
+	t.masked = h[1]&0x80 != 0
+	if t.masked {
+		copy(t.mask[:], h[size-4:])
+	}
+	t.remain, t.pos, t.payload = n, 0, true
+	t.head = t.head[:0]
+	return true
+}
+
+func (t *tetherScanner) read(p []byte) {
+	pos := t.pos
+	t.pos += len(p)
+
+	// the control frames may be in the middle of a fragmented message
+	if t.control || t.skip {
+		return
+	}
+
+	if len(t.msg)+len(p) > tetherScanLimit {
+		t.msg, t.skip = t.msg[:0], true
+		return
+	}
+
+	for i, b := range p {
+		if t.masked {
+			b ^= t.mask[(pos+i)%4]
+		}
+		t.msg = append(t.msg, b)
+	}
+}
+
+func (t *tetherScanner) endFrame() {
+	t.payload = false
+	if t.control || !t.fin {
+		return
+	}
+
+	if !t.skip {
+		t.accept(t.msg)
+	}
+	t.msg, t.skip = t.msg[:0], false
+}
+
+func (t *tetherScanner) accept(msg []byte) {
+	if !bytes.Contains(msg, []byte(`"Tethering.accepted"`)) {
+		return
+	}
+
+	var e struct {
+		Method string `json:"method"`
+		Params struct {
+			ConnectionID string `json:"connectionId"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(msg, &e) != nil || e.Method != "Tethering.accepted" || e.Params.ConnectionID == "" {
+		return
+	}
+
+	t.s.lock.Lock()
+	defer t.s.lock.Unlock()
+	t.s.tethers[e.Params.ConnectionID] = struct{}{}
+}
+
+// pipe the two connections until one of them closes.
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(a, b)
+		_ = a.Close()
+	}()
+
+	_, _ = io.Copy(b, a)
+	_ = b.Close()
+	<-done
+}
+
+// wsStream is the byte stream over the messages of a websocket.
+type wsStream struct {
+	ws interface {
+		cdp.WebSocketable
+		Close() error
+	}
+	buf []byte
+}
+
+func (s *wsStream) Read(p []byte) (int, error) {
+	if len(s.buf) == 0 {
+		msg, err := s.ws.Read()
+		if err != nil {
+			return 0, io.EOF
+		}
+		s.buf = msg
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func (s *wsStream) Write(p []byte) (int, error) {
+	// the Send of the cdp.WebSocket masks the msg in place
+	err := s.ws.Send(append([]byte{}, p...))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *wsStream) Close() error {
+	return s.ws.Close()
+}
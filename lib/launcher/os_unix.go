@@ -3,6 +3,7 @@
 package launcher
 
 import (
+	"os"
 	"os/exec"
 	"syscall"
 
@@ -24,3 +25,10 @@ func (l *Launcher) osSetupCmd(cmd *exec.Cmd) {
 	}
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
+
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
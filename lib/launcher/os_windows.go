@@ -3,6 +3,7 @@
 package launcher
 
 import (
+	"os"
 	"os/exec"
 	"syscall"
 )
@@ -26,3 +27,7 @@ func terminateProcess(pid int) {
 	_ = syscall.TerminateProcess(handle, 0)
 	_ = syscall.CloseHandle(handle)
 }
+
+func exitSignal(_ *os.ProcessState) string {
+	return ""
+}
@@ -2,10 +2,13 @@ package launcher
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -83,14 +86,25 @@ func TestGetURLErr(t *testing.T) {
 	g.Err(err)
 
 	l = New()
-	l.parser.lock.Lock()
-	l.parser.Buffer = "err"
-	l.parser.lock.Unlock()
+	_, _ = l.output.Write([]byte("err"))
 	close(l.exit)
 	_, err = l.getURL()
 	g.Eq("[launcher] Failed to get the debug url: err", err.Error())
 }
 
+func TestRing(t *testing.T) {
+	g := setup(t)
+
+	r := newRing(4)
+	_, _ = r.Write([]byte("ab"))
+	_, _ = r.Write([]byte("cd"))
+	g.Eq(r.String(), "abcd")
+	_, _ = r.Write([]byte("e"))
+	g.Eq(r.String(), "bcde")
+	_, _ = r.Write([]byte("123456"))
+	g.Eq(r.String(), "3456")
+}
+
 func TestManaged(t *testing.T) {
 	g := setup(t)
 
@@ -179,3 +193,171 @@ func TestLaunchClient(t *testing.T) {
 	}
 	g.E(c.Call(ctx, "", "Browser.getVersion", nil))
 }
+
+func TestParseYAML(t *testing.T) {
+	g := setup(t)
+
+	v, err := parseYAML([]byte(`
+--- # doc start
+a: 1
+b: -1.5
+"c d": 'it''s' # comment
+e: "x#y\n"
+f: x # y
+g:
+- 1
+- [a, "b,c", {k: http://a.com}]
+h:
+  - k: v
+    l: null
+  -
+    - nested
+  - - x
+i: ~
+j:
+k: {a: [], b: {}}
+-l: true
+url: http://a.com#hash
+`))
+	g.E(err)
+	g.Eq(v, map[string]interface{}{
+		"a":   1.0,
+		"b":   -1.5,
+		"c d": "it's",
+		"e":   "x#y\n",
+		"f":   "x",
+		"g": []interface{}{
+			1.0,
+			[]interface{}{"a", "b,c", map[string]interface{}{"k": "http://a.com"}},
+		},
+		"h": []interface{}{
+			map[string]interface{}{"k": "v", "l": nil},
+			[]interface{}{"nested"},
+			[]interface{}{"x"},
+		},
+		"i":   nil,
+		"j":   nil,
+		"k":   map[string]interface{}{"a": []interface{}{}, "b": map[string]interface{}{}},
+		"-l":  true,
+		"url": "http://a.com#hash",
+	})
+
+	v, err = parseYAML([]byte("# empty"))
+	g.E(err)
+	g.Nil(v)
+
+	for _, c := range []struct{ in, err string }{
+		{"a: 1\n\tb: 2", "yaml line 2: tabs are not allowed for indentation"},
+		{"a: 1\na: 2", `yaml line 2: duplicate key "a"`},
+		{"a: 1\n  b: 2", "yaml line 2: unexpected indentation"},
+		{"a\nb", "yaml line 1: expect a key"},
+		{`a: "x`, "yaml line 1: unterminated string"},
+		{"a: [1, 2", "yaml line 1: unexpected end"},
+		{"a: [1] x", `yaml line 1: unexpected "x"`},
+		{"a: {b}", `expect ':'`},
+		{"a: &x 1", `yaml line 1: unsupported syntax "&x 1"`},
+		{"a: *x", `yaml line 1: unsupported syntax "*x"`},
+		{"a: !!str 1", `yaml line 1: unsupported syntax "!!str 1"`},
+		{"a: |\n  text", `yaml line 1: unsupported syntax "|"`},
+		{"a: >-\n  text", `yaml line 1: unsupported syntax ">-"`},
+		{"- [*x]", `yaml line 1: unsupported syntax "*x"`},
+		{"a: x\n  y", "yaml line 2: unexpected indentation"},
+		{"? a\n: 1", "yaml line 1: expect a key"},
+		{"a: 1\n---\nb: 2", "yaml line 2: only a single document"},
+		{"%YAML 1.2\n---\na: 1", "yaml line 1: directives are not supported"},
+	} {
+		_, err := parseYAML([]byte(c.in))
+		g.Has(err.Error(), c.err)
+	}
+}
+
+func TestTetherScanner(t *testing.T) {
+	g := setup(t)
+
+	frame := func(fin bool, opcode byte, mask []byte, payload string) []byte {
+		b := []byte{opcode}
+		if fin {
+			b[0] |= 0x80
+		}
+
+		bit := byte(0)
+		if mask != nil {
+			bit = 0x80
+		}
+		switch n := len(payload); {
+		case n < 126:
+			b = append(b, bit|byte(n))
+		case n < 1<<16:
+			b = append(b, bit|126, byte(n>>8), byte(n))
+		default:
+			b = append(b, bit|127, 0, 0, 0, 0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+		}
+
+		data := []byte(payload)
+		if mask != nil {
+			b = append(b, mask...)
+			for i := range data {
+				data[i] ^= mask[i%4]
+			}
+		}
+		return append(b, data...)
+	}
+
+	event := func(id string) string {
+		return `{"method":"Tethering.accepted","params":{"port":1,"connectionId":"` + id + `"}}`
+	}
+
+	s := newManagerSession(nil, nil)
+	sc := &tetherScanner{s: s}
+
+	stream := frame(true, 1, nil, event("a"))
+	// a fragmented and masked message with a ping in the middle
+	e := event("b")
+	stream = append(stream, frame(false, 1, []byte{1, 2, 3, 4}, e[:10])...)
+	stream = append(stream, frame(true, 9, nil, "ping")...)
+	stream = append(stream, frame(true, 0, []byte{5, 6, 7, 8}, e[10:])...)
+	// the large messages are skipped
+	stream = append(stream, frame(true, 1, nil, event(strings.Repeat("c", 1<<16)))...)
+	stream = append(stream, frame(true, 1, nil, `{"id":1,"result":{}}`+strings.Repeat(" ", 200))...)
+	stream = append(stream, frame(true, 1, nil, event("d"))...)
+
+	// the frames may be split anywhere
+	for _, b := range stream {
+		sc.scan([]byte{b})
+	}
+
+	g.Len(s.tethers, 3)
+	g.True(s.takeTether("a"))
+	g.True(s.takeTether("b"))
+	g.True(s.takeTether("d"))
+	g.False(s.takeTether("d"))
+}
+
+func TestManagerAllowedPath(t *testing.T) {
+	g := setup(t)
+
+	before := NewManager().BeforeLaunch
+	check := func(f flags.Flag, p string) int {
+		w := httptest.NewRecorder()
+		l := New().Set(f, p)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					g.Eq(r, http.ErrAbortHandler)
+				}
+			}()
+			before(l, w, nil)
+		}()
+		return w.Code
+	}
+
+	dir := filepath.Join(DefaultUserDataDirPrefix, "a")
+	g.Eq(check(flags.UserDataDir, dir), http.StatusOK)
+	g.Eq(check(flags.UserDataDir, filepath.Join(DefaultUserDataDirPrefix, "..", "..", "a")), http.StatusBadRequest)
+	g.Eq(check(flags.UserDataDir, DefaultUserDataDirPrefix+"-a"), http.StatusBadRequest)
+
+	// the dir of another session can't be used as the template
+	g.Eq(check(flags.UserDataDirTemplate, dir), http.StatusBadRequest)
+	g.Eq(check(flags.UserDataDirTemplate, filepath.Join(DefaultUserDataDirTemplatePrefix, "a")), http.StatusOK)
+	g.Eq(check(flags.UserDataDirTemplate, filepath.Join(DefaultUserDataDirTemplatePrefix, "..", "user-data", "a")), http.StatusBadRequest)
+}
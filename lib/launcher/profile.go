@@ -0,0 +1,490 @@
+package launcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/halicoming/rod/lib/defaults"
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// Profile of the launch settings, check [Load] for the file format.
+type Profile struct {
+	// Extends is the name of the parent profile, the fields of the child override the parent's.
+	Extends string
+
+	Bin      string
+	Revision int
+	Version  string
+	Proxy    string
+
+	// Flags to set, the nil value means a boolean flag.
+	Flags map[flags.Flag][]string
+
+	// RemoveFlags to delete from the launcher, such as the flags set by [New] or the parent profile.
+	RemoveFlags []flags.Flag
+
+	// Env vars to add to the browser process
+	Env map[string]string
+
+	// Preferences json, check [Launcher.Preferences]
+	Preferences string
+
+	// Extensions are the dirs of the unpacked extensions to load.
+	Extensions []string
+}
+
+// Profiles by name, returned by [Load].
+type Profiles map[string]*Profile
+
+// ProfileFiles are the file names to search for when the "profile" option of [defaults] is set.
+// They are searched in the working directory and then its parent directories.
+var ProfileFiles = []string{"rod.yml", "rod.yaml", "rod.json"}
+
+// ErrProfileNotFound is returned when the profile doesn't exist.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// MustLoad is similar to [Load].
+func MustLoad(path string) Profiles {
+	ps, err := Load(path)
+	utils.E(err)
+	return ps
+}
+
+// Load the named profiles from a YAML or JSON file, the format is decided by the file extension.
+// The top-level keys are the profile names, such as:
+//
+//	base:
+//	  bin: /usr/bin/chromium
+//	  proxy: ${PROXY:-127.0.0.1:8080}
+//	  flags:
+//	    window-size: [1280, 720]
+//	    mute-audio: true      # true or empty means a boolean flag
+//	    headless: false       # false removes the flag
+//	  env:
+//	    TZ: UTC
+//	  preferences:
+//	    plugins:
+//	      always_open_pdf_externally: true
+//	  extensions: [./ext/a]
+//
+//	ci:
+//	  extends: base
+//	  revision: 1321438
+//
+// The "${VAR}" in string values is replaced with the env var, "${VAR:-default}" uses the default when
+// the env var is empty, "$$" is a literal "$". The relative paths of the extensions are relative to the file.
+// Only a common subset of YAML is supported, the anchors, block scalars, multi-line scalars, etc. are rejected
+// with an error instead of being misparsed.
+func Load(path string) (Profiles, error) {
+	raw, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw == nil {
+		return Profiles{}, nil
+	}
+	all, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: the top level should be the map of profiles", path)
+	}
+
+	ps := Profiles{}
+	for name, v := range all {
+		p, err := decodeProfile(v, filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: profile %q: %w", path, name, err)
+		}
+		ps[name] = p
+	}
+
+	for name := range ps {
+		_, err = ps.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return ps, nil
+}
+
+// readConfigFile parses the YAML or JSON file by its extension, and expands the env vars in the strings.
+func readConfigFile(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		raw, err = parseYAML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return expandEnv(raw), nil
+}
+
+// Get the profile with its parents merged.
+func (ps Profiles) Get(name string) (*Profile, error) {
+	return ps.get(name, map[string]bool{})
+}
+
+func (ps Profiles) get(name string, visited map[string]bool) (*Profile, error) {
+	p, has := ps[name]
+	if !has {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if visited[name] {
+		return nil, fmt.Errorf("profile %q extends itself", name)
+	}
+	visited[name] = true
+
+	if p.Extends == "" {
+		return p.merge(&Profile{}), nil
+	}
+
+	parent, err := ps.get(p.Extends, visited)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.merge(parent), nil
+}
+
+// Launcher creates a launcher from [New] with the profile applied, the "profile" option of [defaults] is ignored.
+func (ps Profiles) Launcher(name string) (*Launcher, error) {
+	p, err := ps.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return newLauncher().Profile(p), nil
+}
+
+// MustLauncher is similar to [Profiles.Launcher].
+func (ps Profiles) MustLauncher(name string) *Launcher {
+	l, err := ps.Launcher(name)
+	utils.E(err)
+	return l
+}
+
+// Profile applies the profile to the launcher, the [Profile.Extends] is ignored, use [Profiles.Get] to merge the parents.
+func (l *Launcher) Profile(p *Profile) *Launcher {
+	for _, f := range p.RemoveFlags {
+		l.Delete(f)
+	}
+
+	names := []string{}
+	for f := range p.Flags {
+		names = append(names, string(f))
+	}
+	sort.Strings(names)
+	for _, f := range names {
+		l.Set(flags.Flag(f), p.Flags[flags.Flag(f)]...)
+	}
+
+	if p.Bin != "" {
+		l.Bin(p.Bin)
+	}
+	if p.Revision != 0 {
+		l.Revision(p.Revision)
+	}
+	if p.Version != "" {
+		l.Version(p.Version)
+	}
+	if p.Proxy != "" {
+		l.Proxy(p.Proxy)
+	}
+	if p.Preferences != "" {
+		l.Preferences(p.Preferences)
+	}
+
+	if len(p.Env) > 0 {
+		env, has := l.GetFlags(flags.Env)
+		if !has {
+			env = os.Environ()
+		}
+
+		keys := []string{}
+		for k := range p.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, k+"="+p.Env[k])
+		}
+
+		l.Env(env...)
+	}
+
+	if len(p.Extensions) > 0 {
+		l.Set("load-extension", p.Extensions...)
+		l.Set("disable-extensions-except", p.Extensions...)
+	}
+
+	return l
+}
+
+// merge returns a new profile that the fields of p override the parent's.
+func (p *Profile) merge(parent *Profile) *Profile {
+	n := &Profile{
+		Bin:         parent.Bin,
+		Revision:    parent.Revision,
+		Version:     parent.Version,
+		Proxy:       parent.Proxy,
+		Flags:       map[flags.Flag][]string{},
+		Env:         map[string]string{},
+		Preferences: parent.Preferences,
+		Extensions:  append([]string{}, parent.Extensions...),
+	}
+
+	for f, v := range parent.Flags {
+		n.Flags[f] = v
+	}
+	for f, v := range p.Flags {
+		n.Flags[f] = v
+	}
+
+	for _, f := range parent.RemoveFlags {
+		if _, has := p.Flags[f]; !has {
+			n.RemoveFlags = append(n.RemoveFlags, f)
+		}
+	}
+	for _, f := range p.RemoveFlags {
+		delete(n.Flags, f)
+		n.RemoveFlags = append(n.RemoveFlags, f)
+	}
+
+	for k, v := range parent.Env {
+		n.Env[k] = v
+	}
+	for k, v := range p.Env {
+		n.Env[k] = v
+	}
+
+	if p.Bin != "" {
+		n.Bin = p.Bin
+	}
+	if p.Revision != 0 {
+		n.Revision = p.Revision
+	}
+	if p.Version != "" {
+		n.Version = p.Version
+	}
+	if p.Proxy != "" {
+		n.Proxy = p.Proxy
+	}
+	if p.Preferences != "" {
+		n.Preferences = p.Preferences
+	}
+	n.Extensions = append(n.Extensions, p.Extensions...)
+
+	return n
+}
+
+func decodeProfile(v interface{}, dir string) (*Profile, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok && v != nil {
+		return nil, errors.New("should be a map")
+	}
+
+	p := &Profile{}
+
+	for k, v := range m {
+		var err error
+
+		switch k {
+		case "extends":
+			p.Extends, err = profileStr(v)
+		case "bin":
+			p.Bin, err = profileStr(v)
+		case "version":
+			p.Version, err = profileStr(v)
+		case "proxy":
+			p.Proxy, err = profileStr(v)
+		case "revision":
+			var s string
+			s, err = profileStr(v)
+			if err == nil {
+				p.Revision, err = strconv.Atoi(s)
+			}
+		case "flags":
+			err = p.decodeFlags(v)
+		case "env":
+			err = p.decodeEnv(v)
+		case "preferences":
+			p.Preferences, ok = v.(string)
+			if !ok {
+				p.Preferences = utils.MustToJSON(v)
+			}
+		case "extensions":
+			p.Extensions, err = profileList(v)
+			for i, e := range p.Extensions {
+				if !filepath.IsAbs(e) {
+					p.Extensions[i] = filepath.Join(dir, e)
+				}
+			}
+		default:
+			err = errors.New("unknown field")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Profile) decodeFlags(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New("should be a map")
+	}
+
+	p.Flags = map[flags.Flag][]string{}
+	for name, v := range m {
+		f := flags.Flag(name).NormalizeFlag()
+		if strings.Contains(string(f), "=") {
+			return fmt.Errorf("%s: flag name should not contain '='", name)
+		}
+
+		switch v {
+		case nil, true:
+			p.Flags[f] = nil
+		case false:
+			p.RemoveFlags = append(p.RemoveFlags, f)
+		default:
+			list, err := profileList(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			p.Flags[f] = list
+		}
+	}
+	sort.Slice(p.RemoveFlags, func(i, j int) bool { return p.RemoveFlags[i] < p.RemoveFlags[j] })
+
+	return nil
+}
+
+func (p *Profile) decodeEnv(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New("should be a map")
+	}
+
+	p.Env = map[string]string{}
+	for k, v := range m {
+		s, err := profileStr(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+		p.Env[k] = s
+	}
+
+	return nil
+}
+
+// profileStr converts the scalar to string, so that "version: 120" works the same as "version: '120'".
+func profileStr(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("should be a string, got %v", v)
+}
+
+// profileList accepts a scalar or a list of scalars.
+func profileList(v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		list = []interface{}{v}
+	}
+
+	strs := []string{}
+	for _, v := range list {
+		s, err := profileStr(v)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+var regEnvVar = regexp.MustCompile(`\$\$|\$\{(\w+)(:-([^}]*))?\}`)
+
+// expandEnv replaces the env vars in all the strings of the value.
+func expandEnv(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return regEnvVar.ReplaceAllStringFunc(v, func(s string) string {
+			if s == "$$" {
+				return "$"
+			}
+			m := regEnvVar.FindStringSubmatch(s)
+			val := os.Getenv(m[1])
+			if val == "" && m[2] != "" {
+				return m[3]
+			}
+			return val
+		})
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = expandEnv(item)
+		}
+		return list
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range v {
+			m[k] = expandEnv(item)
+		}
+		return m
+	}
+	return v
+}
+
+// defaultProfile loads the profile set by the "profile" option of [defaults] from the [ProfileFiles].
+func defaultProfile() (*Profile, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		for _, name := range ProfileFiles {
+			p := filepath.Join(dir, name)
+			if _, err := os.Stat(p); err == nil {
+				ps, err := Load(p)
+				if err != nil {
+					return nil, err
+				}
+				return ps.Get(defaults.Profile)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("%w: can't find any of %v for the profile %q", ErrProfileNotFound, ProfileFiles, defaults.Profile)
+		}
+		dir = parent
+	}
+}
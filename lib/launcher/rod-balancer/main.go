@@ -0,0 +1,68 @@
+// A server to balance the launch requests across multiple rod-managers
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+var (
+	addr     = flag.String("address", ":7318", "the address to listen to")
+	quiet    = flag.Bool("quiet", false, "silence the log")
+	interval = flag.Duration("interval", 3*time.Second, "the interval between the health checks of the managers")
+	timeout  = flag.Duration("timeout", 5*time.Second, "the timeout of each health check")
+	token    = flag.String("token", "", "the bearer token the clients must send, also read from the env ROD_BALANCER_TOKEN")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: rod-balancer [flags] manager-url...")
+		fmt.Fprintln(flag.CommandLine.Output(), "Such as: rod-balancer ws://a.com:7317 ws://token@b.com:7317")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	b := launcher.MustNewBalancer(flag.Args()...)
+	b.Interval = *interval
+	b.Timeout = *timeout
+
+	if !*quiet {
+		b.Logger = log.New(os.Stdout, "", 0)
+	}
+
+	if *token == "" {
+		*token = os.Getenv("ROD_BALANCER_TOKEN")
+	}
+	if *token != "" {
+		b.Auth = launcher.BearerAuth(map[string]string{"default": *token})
+	}
+
+	b.Start()
+	defer b.Stop()
+
+	l, err := net.Listen("tcp", *addr)
+	utils.E(err)
+
+	if !*quiet {
+		fmt.Println("[rod-balancer] listening on:", l.Addr().String())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", b)
+	mux.HandleFunc("/backends", b.ServeBackends)
+
+	utils.E((&http.Server{Handler: mux}).Serve(l))
+}
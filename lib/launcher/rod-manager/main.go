@@ -2,14 +2,23 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/halicoming/rod/lib/assets"
 	"github.com/halicoming/rod/lib/launcher"
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/metrics/vars"
 	"github.com/halicoming/rod/lib/utils"
 )
 
@@ -17,6 +26,27 @@ var (
 	addr         = flag.String("address", ":7317", "the address to listen to")
 	quiet        = flag.Bool("quiet", false, "silence the log")
 	allowAllPath = flag.Bool("allow-all", false, "allow all path set by the client")
+	noMetrics    = flag.Bool("no-metrics", false, "disable the /metrics and /debug/vars endpoints")
+
+	config       = flag.String("config", "", "the YAML or JSON file of the policy, the flags below override it")
+	token        = flag.String("token", "", "the bearer token the clients must send, also read from the env ROD_MANAGER_TOKEN, added to the tokens of the config")
+	maxSessions  = flag.Int("max-sessions", 0, "the max number of browsers at the same time, 0 means unlimited")
+	queueTimeout = flag.Duration("queue-timeout", 0, "how long a launch can wait for a free slot, 0 means no limit")
+	clientQuota  = flag.Int("client-quota", 0, "the max number of sessions of each client, 0 means unlimited")
+	idleTimeout  = flag.Duration("idle-timeout", 0, "kill the browser if it's idle for the duration, 0 means disabled")
+	maxDuration  = flag.Duration("max-duration", 0, "kill the browser after the duration, 0 means disabled")
+	allowFlags   = flag.String("allow-flags", "", "comma separated browser flags the clients can set besides the defaults")
+	maxReattach  = flag.Duration("max-reattach-grace", 0, "the max duration to keep a browser alive for its client to reattach, 0 means disabled")
+	pool         = flag.Int("pool", 0, "the number of browsers to launch in advance for the clients of launcher.MustNewManaged")
+	adminToken   = flag.String("admin-token", "", "the bearer token of the /admin api and the /status page, "+
+		"also read from the env ROD_MANAGER_ADMIN_TOKEN, they are disabled if it's empty and the clients need auth")
+	balancerToken = flag.String("balancer-token", "", "the bearer token of the rod-balancer in front of the manager, "+
+		"the client names it forwards are trusted, also read from the env ROD_MANAGER_BALANCER_TOKEN")
+
+	tlsCert  = flag.String("tls-cert", "", "the certificate file to serve https")
+	tlsKey   = flag.String("tls-key", "", "the private key file to serve https")
+	clientCA = flag.String("client-ca", "", "the CA file to verify the client certificates, it enables the mTLS auth, "+
+		"it can't be used with the tokens")
 )
 
 func main() {
@@ -32,6 +62,16 @@ func main() {
 		m.BeforeLaunch = func(_ *launcher.Launcher, _ http.ResponseWriter, _ *http.Request) {}
 	}
 
+	setupPolicy(m)
+
+	if *pool > 0 {
+		m.PoolSize = *pool
+		// the same flags as the ones set by launcher.MustNewManaged
+		m.PoolLauncher = func() *launcher.Launcher { return launcher.New().Set("disable-http2") }
+		m.StartPool()
+		defer m.StopPool()
+	}
+
 	l, err := net.Listen("tcp", *addr)
 	if err != nil {
 		utils.E(err)
@@ -41,6 +81,122 @@ func main() {
 		fmt.Println("[rod-manager] listening on:", l.Addr().String())
 	}
 
-	srv := &http.Server{Handler: m}
-	utils.E(srv.Serve(l))
+	srv := newServer(m)
+
+	if *tlsCert == "" {
+		utils.E(srv.Serve(l))
+		return
+	}
+
+	utils.E(srv.ServeTLS(l, *tlsCert, *tlsKey))
+}
+
+func newServer(m *launcher.Manager) *http.Server {
+	srv := &http.Server{}
+
+	// the auth must be set before the admin api, it decides whether the api is exposed to the clients
+	if *tlsCert != "" && *clientCA != "" {
+		if m.Auth != nil {
+			utils.E(errors.New("the --client-ca can't be used with the tokens, the mTLS auth replaces them"))
+		}
+
+		pem, err := os.ReadFile(*clientCA)
+		utils.E(err)
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			utils.E(fmt.Errorf("no certificate found in %s", *clientCA))
+		}
+
+		srv.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+		}
+		m.Auth = launcher.CertAuth()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", m)
+	setupAdmin(mux, m)
+	if !*noMetrics {
+		vars.Publish()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+
+	srv.Handler = mux
+	return srv
+}
+
+func setupPolicy(m *launcher.Manager) {
+	// the tokens of the flags are merged into the ones of the config
+	tokens := map[string]string{}
+	if *config != "" {
+		c := launcher.MustLoadManagerConfig(*config)
+		c.Apply(m)
+		for client, t := range c.Tokens {
+			tokens[client] = t
+		}
+	}
+
+	if *token == "" {
+		*token = os.Getenv("ROD_MANAGER_TOKEN")
+	}
+	if *balancerToken == "" {
+		*balancerToken = os.Getenv("ROD_MANAGER_BALANCER_TOKEN")
+	}
+	if *token != "" {
+		tokens["default"] = *token
+	}
+	if *balancerToken != "" {
+		tokens["balancer"] = *balancerToken
+	}
+	if len(tokens) > 0 {
+		m.Auth = launcher.ForwardedAuth(launcher.BearerAuth(tokens), "balancer")
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "max-sessions":
+			m.MaxSessions = *maxSessions
+		case "queue-timeout":
+			m.QueueTimeout = *queueTimeout
+		case "client-quota":
+			m.ClientQuota = *clientQuota
+		case "idle-timeout":
+			m.IdleTimeout = *idleTimeout
+		case "max-duration":
+			m.MaxDuration = *maxDuration
+		case "max-reattach-grace":
+			m.MaxReattachGrace = *maxReattach
+		case "allow-flags":
+			m.AllowedFlags = []flags.Flag{}
+			for _, name := range strings.Split(*allowFlags, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					m.AllowedFlags = append(m.AllowedFlags, flags.Flag(name).NormalizeFlag())
+				}
+			}
+		}
+	})
+}
+
+func setupAdmin(mux *http.ServeMux, m *launcher.Manager) {
+	if *adminToken == "" {
+		*adminToken = os.Getenv("ROD_MANAGER_ADMIN_TOKEN")
+	}
+
+	var auth func(*http.Request) (string, error)
+	if *adminToken != "" {
+		auth = launcher.BearerAuth(map[string]string{"admin": *adminToken})
+	} else if m.Auth != nil {
+		// don't let the clients manage the sessions of each other
+		return
+	}
+
+	mux.Handle("/admin/", http.StripPrefix("/admin", m.AdminHandler(auth)))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		utils.E(w.Write([]byte(assets.ManagerStatus)))
+	})
 }
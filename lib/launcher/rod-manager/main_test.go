@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher"
+	"github.com/ysmood/got"
+)
+
+func TestClientCA(t *testing.T) {
+	g := got.T(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	g.E(err)
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	g.E(err)
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	g.E(os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+
+	t.Setenv("ROD_MANAGER_ADMIN_TOKEN", "")
+	*tlsCert, *clientCA, *noMetrics = "cert.pem", ca, true
+	defer func() { *tlsCert, *clientCA, *noMetrics, *adminToken = "", "", false, "" }()
+
+	cert, err := x509.ParseCertificate(der)
+	g.E(err)
+
+	// a request of a verified mTLS client
+	get := func(srv *http.Server, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		res := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(res, req)
+		return res
+	}
+
+	// the mTLS clients can't manage the sessions of each other, the requests go to the manager instead
+	m := launcher.NewManager()
+	srv := newServer(m)
+	g.NotNil(m.Auth)
+	g.NotNil(srv.TLSConfig.ClientCAs)
+	g.Has(get(srv, "/admin/sessions").Body.String(), `"flags"`)
+	g.Has(get(srv, "/status").Body.String(), `"flags"`)
+
+	*adminToken = "secret"
+	srv = newServer(launcher.NewManager())
+	g.Eq(get(srv, "/admin/sessions").Code, http.StatusUnauthorized)
+
+	// the mTLS auth can't silently replace the tokens
+	m = launcher.NewManager()
+	m.Auth = launcher.BearerAuth(map[string]string{"a": "token-a"})
+	g.Panic(func() { newServer(m) })
+}
+
+func TestSetupPolicy(t *testing.T) {
+	g := got.T(t)
+
+	file := filepath.Join(t.TempDir(), "policy.yml")
+	g.E(os.WriteFile(file, []byte("tokens:\n  a: token-a\n  default: token-old\nmax-sessions: 3\n"), 0o644))
+
+	t.Setenv("ROD_MANAGER_TOKEN", "")
+	*config, *token, *balancerToken = file, "token-default", "token-balancer"
+	defer func() { *config, *token, *balancerToken = "", "", "" }()
+
+	m := launcher.NewManager()
+	setupPolicy(m)
+	g.Eq(m.MaxSessions, 3)
+
+	auth := func(token, forwarded string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(launcher.ClientHeaderName, forwarded)
+		client, err := m.Auth(req)
+		if err != nil {
+			return err.Error()
+		}
+		return client
+	}
+
+	// the tokens of the flags are merged into the ones of the config
+	g.Eq(auth("token-a", ""), "a")
+	g.Eq(auth("token-a", "x"), "a")
+	g.Eq(auth("token-default", ""), "default")
+	g.Eq(auth("token-balancer", "x"), "balancer/x")
+	g.Has(auth("token-old", ""), "unauthorized")
+}
@@ -0,0 +1,423 @@
+package launcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/utils"
+	"github.com/ysmood/goob"
+)
+
+// Supervisor keeps a browser running. It launches the browser with a [Launcher], watches the process,
+// and restarts it with backoff when it exits for any reason other than [Supervisor.Stop].
+// Because a [Launcher] can only be launched once, each restart uses a copy of the original one,
+// so the restarted browser shares the same flags, such as the [flags.UserDataDir].
+type Supervisor struct {
+	// Logger for key events
+	Logger utils.Logger
+
+	// Sleeper to wait between the restarts, it's called to create a new backoff sleeper each time the browser
+	// has been running longer than the Stable duration. If the sleeper returns an error, the supervisor gives up.
+	Sleeper func() utils.Sleeper
+
+	// Stable is the running duration after which the backoff is reset.
+	Stable time.Duration
+
+	// Limits of the resources the browser processes can use, it only works on Linux.
+	Limits Limits
+
+	template *Launcher
+	ctx      context.Context
+	cancel   func()
+	event    *goob.Observable
+
+	lock     sync.Mutex
+	current  *Launcher
+	url      string
+	restarts int
+	release  func()
+	started  bool
+	err      error
+	done     chan struct{}
+}
+
+// Restart event published by the [Supervisor] after the browser is restarted.
+type Restart struct {
+	// URL is the new control url of the browser
+	URL string
+
+	// Restarts is the number of restarts so far
+	Restarts int
+
+	// Err why the previous browser exited, it's nil if the browser exited normally.
+	Err error
+}
+
+// NewSupervisor for the launcher, the launcher itself won't be launched, it's only used as a template.
+func NewSupervisor(l *Launcher) *Supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Supervisor{
+		Logger: utils.LoggerQuiet,
+		Sleeper: func() utils.Sleeper {
+			return utils.BackoffSleeper(100*time.Millisecond, 30*time.Second, nil)
+		},
+		Stable:   time.Minute,
+		template: l,
+		ctx:      ctx,
+		cancel:   cancel,
+		event:    goob.New(ctx),
+		release:  func() {},
+		done:     make(chan struct{}),
+	}
+}
+
+// MustStart is similar to [Supervisor.Start].
+func (s *Supervisor) MustStart() string {
+	u, err := s.Start()
+	utils.E(err)
+	return u
+}
+
+// Start launches the browser and starts to watch it, it returns the control url.
+// If the first launch fails, the error is returned without retrying.
+func (s *Supervisor) Start() (string, error) {
+	s.lock.Lock()
+	if s.started {
+		s.lock.Unlock()
+		return "", ErrAlreadyLaunched
+	}
+	s.started = true
+	s.lock.Unlock()
+
+	u, err := s.launch()
+	if err != nil {
+		close(s.done)
+		return "", err
+	}
+
+	go s.watch()
+
+	return u, nil
+}
+
+// URL returns the control url of the current browser.
+func (s *Supervisor) URL() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.url
+}
+
+// Launcher returns the launcher of the current browser, it's nil before [Supervisor.Start].
+func (s *Supervisor) Launcher() *Launcher {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.current
+}
+
+// PID returns the pid of the current browser.
+func (s *Supervisor) PID() int {
+	if l := s.Launcher(); l != nil {
+		return l.PID()
+	}
+	return 0
+}
+
+// Restarts returns the number of restarts so far.
+func (s *Supervisor) Restarts() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.restarts
+}
+
+// Subscribe to the [Restart] events, the channel will be closed when the ctx is done or the supervisor stops.
+func (s *Supervisor) Subscribe(ctx context.Context) <-chan *Restart {
+	src := s.event.Subscribe(ctx)
+	dst := make(chan *Restart)
+	go func() {
+		defer close(dst)
+		for e := range src {
+			select {
+			case <-ctx.Done():
+				return
+			case dst <- e.(*Restart): //nolint: forcetypeassert
+			}
+		}
+	}()
+	return dst
+}
+
+// Done is closed after the supervisor stops watching the browser.
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the supervisor stopped, it's [context.Canceled] after [Supervisor.Stop],
+// or the error of the [Supervisor.Sleeper] if the supervisor gives up.
+func (s *Supervisor) Err() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Stop restarting and kill the browser. It won't remove the [flags.UserDataDir],
+// use [Launcher.Cleanup] of [Supervisor.Launcher] for that.
+// If the supervisor isn't started yet, it returns immediately and the supervisor can't be started anymore.
+func (s *Supervisor) Stop() {
+	s.lock.Lock()
+	started := s.started
+	if !started {
+		s.started = true
+		s.err = context.Canceled
+		close(s.done)
+	}
+	s.lock.Unlock()
+
+	s.cancel()
+
+	if !started {
+		return
+	}
+
+	if l := s.Launcher(); l != nil {
+		l.Kill()
+	}
+
+	<-s.done
+}
+
+func (s *Supervisor) launch() (string, error) {
+	l := s.template.clone()
+	l.Context(s.ctx)
+
+	u, err := l.Launch()
+	if err != nil {
+		return "", err
+	}
+
+	release, err := applyLimits(l.PID(), s.Limits)
+	if err != nil {
+		s.Logger.Println("[launcher.Supervisor] failed to apply the limits:", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ctx.Err() != nil {
+		killGroup(l.PID())
+		go release()
+		return "", s.ctx.Err()
+	}
+
+	s.current = l
+	s.url = u
+	s.release = release
+
+	return u, nil
+}
+
+func (s *Supervisor) watch() {
+	defer close(s.done)
+
+	sleeper := s.Sleeper()
+
+	for {
+		l := s.Launcher()
+		start := time.Now()
+
+		exitErr := l.Wait()
+
+		// kill the orphan children of the browser, the release may wait for them to exit, don't delay the restart
+		killGroup(l.PID())
+		s.lock.Lock()
+		go s.release()
+		s.release = func() {}
+		s.lock.Unlock()
+
+		if s.stopped(s.ctx.Err()) {
+			return
+		}
+
+		s.Logger.Println("[launcher.Supervisor] the browser exited, restarting:", l.ExitStatus(), exitErr)
+
+		if time.Since(start) > s.Stable {
+			sleeper = s.Sleeper()
+		}
+
+		for {
+			if s.stopped(sleeper(s.ctx)) {
+				return
+			}
+
+			u, err := s.launch()
+			if s.stopped(s.ctx.Err()) {
+				return
+			}
+			if err != nil {
+				s.Logger.Println("[launcher.Supervisor] failed to restart the browser:", err)
+				continue
+			}
+
+			s.lock.Lock()
+			s.restarts++
+			e := &Restart{URL: u, Restarts: s.restarts, Err: exitErr}
+			s.lock.Unlock()
+
+			s.event.Publish(e)
+			break
+		}
+	}
+}
+
+func (s *Supervisor) stopped(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+
+	if !errors.Is(err, context.Canceled) {
+		s.Logger.Println("[launcher.Supervisor] gave up restarting the browser:", err)
+	}
+
+	return true
+}
+
+// clone the launcher so that the clone can be launched.
+func (l *Launcher) clone() *Launcher {
+	n := newLauncher()
+	n.Flags = map[flags.Flag][]string{}
+	for k, v := range l.Flags {
+		if v != nil {
+			v = append([]string{}, v...)
+		}
+		n.Flags[k] = v
+	}
+	n.browser = l.browser
+	n.logger = l.logger
+	n.err = l.err
+	return n
+}
+
+// MustClient is similar to [Supervisor.Client].
+func (s *Supervisor) MustClient() *SupervisedClient {
+	c, err := s.Client()
+	utils.E(err)
+	return c
+}
+
+// Client connects to the current browser, and reconnects to the new browser after each restart.
+// Use it with rod like:
+//
+//	rod.New().Client(supervisor.MustClient()).MustConnect()
+//
+// The pages of the previous browser are gone after a restart, so the calls to them will fail.
+func (s *Supervisor) Client() (*SupervisedClient, error) {
+	c, err := cdp.StartWithURL(s.ctx, s.URL(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &SupervisedClient{
+		s:      s,
+		client: c,
+		event:  make(chan *cdp.Event),
+	}
+
+	restarts := s.Subscribe(s.ctx)
+	sc.forward(c)
+
+	go func() {
+		for r := range restarts {
+			sc.reconnect(r.URL)
+		}
+		sc.wg.Wait()
+		close(sc.event)
+	}()
+
+	return sc, nil
+}
+
+// SupervisedClient is a cdp client created by [Supervisor.Client].
+type SupervisedClient struct {
+	s *Supervisor
+
+	lock   sync.Mutex
+	client *cdp.Client
+
+	// the params of the Target.setDiscoverTargets call to replay after reconnecting,
+	// so that the target events keep coming from the new browser
+	discover interface{}
+
+	event chan *cdp.Event
+	wg    sync.WaitGroup
+}
+
+// Call a method of the current browser.
+func (c *SupervisedClient) Call(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+	c.lock.Lock()
+	client := c.client
+	if sessionID == "" && method == "Target.setDiscoverTargets" {
+		c.discover = params
+	}
+	c.lock.Unlock()
+
+	return client.Call(ctx, sessionID, method, params)
+}
+
+// Event returns the events of all the browsers, it's closed after the supervisor stops.
+func (c *SupervisedClient) Event() <-chan *cdp.Event {
+	return c.event
+}
+
+func (c *SupervisedClient) reconnect(u string) {
+	client, err := cdp.StartWithURL(c.s.ctx, u, nil)
+	if err != nil {
+		c.s.Logger.Println("[launcher.Supervisor] failed to reconnect:", err)
+		return
+	}
+
+	c.lock.Lock()
+	c.client = client
+	discover := c.discover
+	c.lock.Unlock()
+
+	c.forward(client)
+
+	if discover != nil {
+		_, err = client.Call(c.s.ctx, "", "Target.setDiscoverTargets", discover)
+		if err != nil {
+			c.s.Logger.Println("[launcher.Supervisor] failed to discover targets:", err)
+		}
+	}
+}
+
+func (c *SupervisedClient) forward(client *cdp.Client) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		events := client.Event()
+		for {
+			select {
+			case <-c.s.ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case <-c.s.ctx.Done():
+					return
+				case c.event <- e:
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,149 @@
+package launcher
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+	"github.com/halicoming/rod/lib/utils"
+)
+
+// UserDataDirFrom sets a prepared user data dir as the template, such as a dir with extensions installed,
+// preferences set, or cookies of logged-in accounts. Before each launch the template will be copied into the
+// [Launcher.UserDataDir], so parallel browsers won't share or modify the template.
+// The copy is copy-on-write if the file system supports it, such as btrfs and xfs on Linux.
+// If the [Launcher.UserDataDir] is not empty, the copy will be skipped, such as when the [Supervisor] relaunches the browser.
+// The [Launcher.Preferences] will override the preferences of the template.
+// Use [Launcher.SnapshotUserDataDir] to create a template from a session.
+func (l *Launcher) UserDataDirFrom(template string) *Launcher {
+	if template == "" {
+		return l.Delete(flags.UserDataDirTemplate)
+	}
+
+	if !l.Has(flags.UserDataDir) {
+		l.UserDataDir(filepath.Join(DefaultUserDataDirPrefix, utils.RandString(8)))
+	}
+
+	return l.Set(flags.UserDataDirTemplate, template)
+}
+
+// SnapshotUserDataDir copies the [Launcher.UserDataDir] to the dest dir, the dest can be used as the template of
+// [Launcher.UserDataDirFrom]. The dest should not exist. The lock files of the browser will be skipped.
+// Close the browser before the snapshot, or the recent changes may not be flushed to the disk yet,
+// call it before the [Launcher.Cleanup] because the cleanup removes the user data dir.
+func (l *Launcher) SnapshotUserDataDir(dest string) error {
+	dir := l.Get(flags.UserDataDir)
+	if dir == "" {
+		return fmt.Errorf("[launcher] no user data dir to snapshot")
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("[launcher] snapshot dest already exists: %s", dest)
+	}
+
+	return copyUserDataDir(dir, dest)
+}
+
+// MustSnapshotUserDataDir is similar to [Launcher.SnapshotUserDataDir].
+func (l *Launcher) MustSnapshotUserDataDir(dest string) *Launcher {
+	utils.E(l.SnapshotUserDataDir(dest))
+	return l
+}
+
+// setupUserDataDir copies the template into the user data dir.
+func (l *Launcher) setupUserDataDir() error {
+	template := l.Get(flags.UserDataDirTemplate)
+	dir := l.Get(flags.UserDataDir)
+	if template == "" || dir == "" {
+		return nil
+	}
+
+	if list, err := os.ReadDir(dir); err == nil && len(list) > 0 {
+		return nil
+	}
+
+	info, err := os.Stat(template)
+	if err != nil {
+		return fmt.Errorf("[launcher] invalid user data dir template: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("[launcher] user data dir template is not a dir: %s", template)
+	}
+
+	return copyUserDataDir(template, dir)
+}
+
+// copyUserDataDir copies the src dir to the dest dir, it skips the lock files of the browser.
+func copyUserDataDir(src, dest string) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		if rel != "." && isUserDataDirLock(d.Name()) {
+			return nil
+		}
+
+		target := filepath.Join(dest, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+
+		case d.Type().IsRegular():
+			return copyFile(p, target, info.Mode().Perm())
+		}
+
+		// skip the sockets, pipes, and devices
+		return nil
+	})
+}
+
+// isUserDataDirLock checks if the file is used by the browser to lock the user data dir.
+func isUserDataDirLock(name string) bool {
+	return strings.HasPrefix(name, "Singleton") || name == "lockfile"
+}
+
+func copyFile(src, dest string, perm fs.FileMode) error {
+	from, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = from.Close() }()
+
+	to, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if cloneFile(to, from) != nil {
+		_, err = io.Copy(to, from)
+		if err != nil {
+			_ = to.Close()
+			return err
+		}
+	}
+
+	return to.Close()
+}
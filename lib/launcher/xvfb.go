@@ -0,0 +1,233 @@
+package launcher
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/halicoming/rod/lib/launcher/flags"
+)
+
+// XvfbScreen makes the launcher start and own an Xvfb process for the browser instead of wrapping it
+// with xvfb-run, such as XvfbScreen(1920, 1080, 24). The Xvfb picks a free display number by itself,
+// the display is passed to the browser as the DISPLAY env var. The Xvfb is stopped when the browser exits,
+// no matter it's killed by [Launcher.Kill] or exits by itself. Use it with [Launcher.Headless] disabled.
+func (l *Launcher) XvfbScreen(width, height, depth int) *Launcher {
+	return l.Set(flags.XvfbScreen, fmt.Sprintf("%dx%dx%d", width, height, depth))
+}
+
+// XvfbDisplay returns the display of the Xvfb started by [Launcher.XvfbScreen], such as ":99".
+// It's empty if the Xvfb isn't running.
+func (l *Launcher) XvfbDisplay() string {
+	if !l.xvfb.running() {
+		return ""
+	}
+	return l.xvfb.display
+}
+
+// XvfbScreenshot captures the root window of the Xvfb started by [Launcher.XvfbScreen],
+// it includes the whole screen, such as the native dialogs and the browser UI that the CDP can't capture.
+func (l *Launcher) XvfbScreenshot() (image.Image, error) {
+	if !l.xvfb.running() {
+		return nil, fmt.Errorf("%w: not running", ErrXvfb)
+	}
+
+	// Xvfb keeps the framebuffer of the screen in the file as a XWD image
+	f, err := os.Open(filepath.Join(l.xvfb.fbdir, "Xvfb_screen0"))
+	if err != nil {
+		if !l.xvfb.running() {
+			// stopped after the check above
+			return nil, fmt.Errorf("%w: not running", ErrXvfb)
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	return decodeXWD(f)
+}
+
+type xvfb struct {
+	cmd     *exec.Cmd
+	display string
+	fbdir   string
+	output  *ring
+	exit    chan struct{}
+	once    sync.Once
+}
+
+// startXvfb with the screen of [flags.XvfbScreen], it waits until the Xvfb is ready for the connections.
+func (l *Launcher) startXvfb() (*xvfb, error) {
+	bin, err := exec.LookPath("Xvfb")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrXvfb, err)
+	}
+
+	fbdir, err := os.MkdirTemp("", "rod-xvfb-")
+	if err != nil {
+		return nil, err
+	}
+
+	// Xvfb writes the display number to the fd when it's ready
+	r, w, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(fbdir)
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	x := &xvfb{
+		fbdir:  fbdir,
+		output: newRing(DefaultOutputSize),
+		exit:   make(chan struct{}),
+	}
+
+	x.cmd = exec.Command(bin,
+		"-displayfd", "3",
+		"-screen", "0", l.Get(flags.XvfbScreen),
+		"-fbdir", fbdir,
+		"-nolisten", "tcp",
+	)
+	x.cmd.ExtraFiles = []*os.File{w}
+	x.cmd.Stdout = io.MultiWriter(l.logger, x.output)
+	x.cmd.Stderr = io.MultiWriter(l.logger, x.output)
+	x.cmd.SysProcAttr = xvfbSysProcAttr(l.Has(flags.Leakless))
+	x.cmd.WaitDelay = time.Second
+
+	err = x.cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		_ = os.RemoveAll(fbdir)
+		return nil, fmt.Errorf("%w: %w", ErrXvfb, err)
+	}
+
+	go func() {
+		_ = x.cmd.Wait()
+		close(x.exit)
+	}()
+
+	display := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		display <- strings.TrimSpace(line)
+	}()
+
+	select {
+	case n := <-display:
+		if n != "" {
+			x.display = ":" + n
+			return x, nil
+		}
+		// wait for the output of the exited Xvfb
+		<-x.exit
+		err = fmt.Errorf("%w: exited: %s", ErrXvfb, strings.TrimSpace(x.output.String()))
+	case <-l.ctx.Done():
+		err = l.ctx.Err()
+	}
+
+	x.stop()
+	return nil, err
+}
+
+// running returns false if the Xvfb isn't started, or it's stopped by [xvfb.stop] or exited by itself.
+func (x *xvfb) running() bool {
+	if x == nil {
+		return false
+	}
+	select {
+	case <-x.exit:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop the Xvfb and remove its framebuffer dir, [xvfb.running] is false after it.
+func (x *xvfb) stop() {
+	x.once.Do(func() {
+		_ = x.cmd.Process.Kill()
+		<-x.exit
+		_ = os.RemoveAll(x.fbdir)
+	})
+}
+
+// decodeXWD decodes the true color ZPixmap of the X Window Dump format, such as the framebuffer file of Xvfb.
+func decodeXWD(r io.Reader) (image.Image, error) {
+	var h struct {
+		HeaderSize, FileVersion, PixmapFormat, PixmapDepth, PixmapWidth, PixmapHeight, XOffset,
+		ByteOrder, BitmapUnit, BitmapBitOrder, BitmapPad, BitsPerPixel, BytesPerLine, VisualClass,
+		RedMask, GreenMask, BlueMask, BitsPerRGB, ColormapEntries, NColors,
+		WindowWidth, WindowHeight, WindowX, WindowY, WindowBorderWidth uint32
+	}
+
+	err := binary.Read(r, binary.BigEndian, &h)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xwd header: %w", err)
+	}
+
+	const trueColor = 4
+	bpp := int(h.BitsPerPixel / 8)
+	if h.FileVersion != 7 || h.PixmapFormat != 2 || h.VisualClass < trueColor ||
+		(bpp != 2 && bpp != 3 && bpp != 4) || h.HeaderSize < uint32(binary.Size(h)) {
+		return nil, fmt.Errorf("unsupported xwd: version %d, format %d, visual %d, %d bits per pixel",
+			h.FileVersion, h.PixmapFormat, h.VisualClass, h.BitsPerPixel)
+	}
+
+	// skip the window name and the colormap
+	_, err = io.CopyN(io.Discard, r, int64(h.HeaderSize)-int64(binary.Size(h))+int64(h.NColors)*12)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xwd header: %w", err)
+	}
+
+	w, ht, stride := int(h.PixmapWidth), int(h.PixmapHeight), int(h.BytesPerLine)
+	if stride < w*bpp {
+		return nil, fmt.Errorf("invalid xwd bytes per line: %d", stride)
+	}
+
+	channel := func(p, mask uint32) uint8 {
+		if mask == 0 {
+			return 0
+		}
+		v := (p & mask) >> bits.TrailingZeros32(mask)
+		return uint8(v * 255 / (mask >> bits.TrailingZeros32(mask)))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, ht))
+	line := make([]byte, stride)
+	for y := 0; y < ht; y++ {
+		_, err = io.ReadFull(r, line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xwd pixels: %w", err)
+		}
+
+		for x := 0; x < w; x++ {
+			var p uint32
+			for i := 0; i < bpp; i++ {
+				b := uint32(line[x*bpp+i])
+				if h.ByteOrder == 0 { // LSBFirst
+					p |= b << (8 * i)
+				} else {
+					p = p<<8 | b
+				}
+			}
+
+			img.SetRGBA(x, y, color.RGBA{
+				R: channel(p, h.RedMask),
+				G: channel(p, h.GreenMask),
+				B: channel(p, h.BlueMask),
+				A: 0xff,
+			})
+		}
+	}
+
+	return img, nil
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package launcher
+
+import "syscall"
+
+// xvfbSysProcAttr kills the Xvfb when the Go process exits if the leakless is enabled.
+func xvfbSysProcAttr(leakless bool) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if leakless {
+		attr.Pdeathsig = syscall.SIGKILL
+	}
+	return attr
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package launcher
+
+import "syscall"
+
+func xvfbSysProcAttr(_ bool) *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,384 @@
+package launcher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseYAML parses the subset of YAML that is enough for config files into the same types
+// as json.Unmarshal does with interface{}. The subset is:
+//
+//   - block mappings and sequences indented with spaces, such as "a:\n  - 1"
+//   - flow mappings and sequences on a single line, such as "{a: [1, 2]}"
+//   - plain, single-quoted, and double-quoted scalars on a single line, as values or keys
+//   - null, bool, and number scalars, numbers are float64 like json
+//   - comments, and a "---" before the document
+//
+// Anything else is an error instead of being misparsed, such as the anchors, aliases, tags, directives,
+// block scalars ("|" and ">"), multi-line scalars, complex keys ("? "), and multiple documents.
+// A plain scalar that starts with one of the indicators "&*!|>%@`" should be quoted.
+// Because JSON is a subset of YAML, a JSON document on a single line is also accepted.
+func parseYAML(data []byte) (interface{}, error) {
+	p := &yamlParser{}
+
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if strings.Contains(raw, "\t") && strings.TrimLeft(raw, "\t ") != strings.TrimLeft(raw, " ") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" {
+			continue
+		}
+		if trimmed == "---" || trimmed == "..." || strings.HasPrefix(trimmed, "--- ") {
+			if len(p.lines) > 0 || trimmed != "---" {
+				return nil, fmt.Errorf("yaml line %d: only a single document without content on the marker is supported", i+1)
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "%") {
+			return nil, fmt.Errorf("yaml line %d: directives are not supported", i+1)
+		}
+
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.i < len(p.lines) {
+		return nil, p.errorf("unexpected indentation")
+	}
+
+	return v, nil
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("yaml line %d: %s", p.lines[p.i].num, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isYAMLSeqItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLSeqItem(p.lines[p.i].text) {
+		l := p.lines[p.i]
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+
+		if rest == "" {
+			p.i++
+			v, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+
+		if _, _, ok := splitYAMLKey(rest); ok || isYAMLSeqItem(rest) {
+			// such as "- a: 1", treat the rest as a nested block
+			p.lines[p.i] = yamlLine{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+			v, err := p.block(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+
+		v, err := parseYAMLValue(rest)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		list = append(list, v)
+		p.i++
+	}
+
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		key, rest, ok := splitYAMLKey(p.lines[p.i].text)
+		if !ok {
+			return nil, p.errorf("expect a key")
+		}
+		if _, has := m[key]; has {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+
+		if rest != "" {
+			v, err := parseYAMLValue(rest)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			m[key] = v
+			p.i++
+			continue
+		}
+
+		p.i++
+
+		// a sequence is allowed to have the same indentation as its key
+		if p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLSeqItem(p.lines[p.i].text) {
+			v, err := p.sequence(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		v, err := p.child(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+
+	return m, nil
+}
+
+// child returns the nested block that is more indented than the parent, or nil if there's none.
+func (p *yamlParser) child(parent int) (interface{}, error) {
+	if p.i >= len(p.lines) || p.lines[p.i].indent <= parent {
+		return nil, nil
+	}
+	return p.block(p.lines[p.i].indent)
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+var regYAMLKey = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s"'\[\]{},#&*!|>%@` + "`" + `-][^:]*?|-[^\s:][^:]*?)\s*:(?:\s+(.*))?$`)
+
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	m := regYAMLKey.FindStringSubmatch(text)
+	if m == nil {
+		return "", "", false
+	}
+
+	k, err := parseYAMLScalar(m[1])
+	if err != nil {
+		return "", "", false
+	}
+
+	return fmt.Sprint(k), m[2], true
+}
+
+// stripYAMLComment removes the comment that starts with " #" outside of the quotes.
+func stripYAMLComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseYAMLValue(s string) (interface{}, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		f := &yamlFlow{s: s}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.space()
+		if f.i < len(f.s) {
+			return nil, fmt.Errorf("unexpected %q", f.s[f.i:])
+		}
+		return v, nil
+	}
+	return parseYAMLScalar(s)
+}
+
+// yamlIndicators can't start a plain scalar of the supported subset.
+const yamlIndicators = "&*!|>%@`"
+
+var (
+	regYAMLInt   = regexp.MustCompile(`^[-+]?\d+$`)
+	regYAMLFloat = regexp.MustCompile(`^[-+]?(\d+\.\d*|\.\d+|\d+)([eE][-+]?\d+)?$`)
+)
+
+func parseYAMLScalar(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s)
+
+	case strings.HasPrefix(s, `'`):
+		if len(s) < 2 || !strings.HasSuffix(s, `'`) {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if strings.ContainsRune(yamlIndicators, rune(s[0])) {
+		return nil, fmt.Errorf("unsupported syntax %q, the anchors, aliases, tags, block scalars, "+
+			"and directives are not supported, quote it if it's a string", s)
+	}
+
+	// same as json.Unmarshal, numbers are float64
+	if regYAMLInt.MatchString(s) || regYAMLFloat.MatchString(s) {
+		return strconv.ParseFloat(s, 64)
+	}
+
+	return s, nil
+}
+
+// yamlFlow parses the flow style collections, such as "[a, b]" and "{a: 1}".
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) space() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.space()
+	if f.i >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of %q", f.s)
+	}
+
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		list := []interface{}{}
+		err := f.items(']', func() error {
+			v, err := f.value()
+			list = append(list, v)
+			return err
+		})
+		return list, err
+
+	case '{':
+		f.i++
+		m := map[string]interface{}{}
+		err := f.items('}', func() error {
+			k, err := f.scalar(":")
+			if err != nil {
+				return err
+			}
+			if f.i >= len(f.s) || f.s[f.i] != ':' {
+				return fmt.Errorf("expect ':' in %q", f.s)
+			}
+			f.i++
+			v, err := f.value()
+			m[fmt.Sprint(k)] = v
+			return err
+		})
+		return m, err
+	}
+
+	return f.scalar("")
+}
+
+func (f *yamlFlow) items(end byte, item func() error) error {
+	f.space()
+	if f.i < len(f.s) && f.s[f.i] == end {
+		f.i++
+		return nil
+	}
+
+	for {
+		err := item()
+		if err != nil {
+			return err
+		}
+
+		f.space()
+		if f.i >= len(f.s) {
+			return fmt.Errorf("unexpected end of %q", f.s)
+		}
+
+		switch f.s[f.i] {
+		case ',':
+			f.i++
+		case end:
+			f.i++
+			return nil
+		default:
+			return fmt.Errorf("unexpected %q in %q", f.s[f.i], f.s)
+		}
+	}
+}
+
+func (f *yamlFlow) scalar(stops string) (interface{}, error) {
+	f.space()
+	start := f.i
+
+	if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+		q := f.s[f.i]
+		for f.i++; f.i < len(f.s); f.i++ {
+			if q == '"' && f.s[f.i] == '\\' {
+				f.i++
+			} else if f.s[f.i] == q {
+				if q == '\'' && f.i+1 < len(f.s) && f.s[f.i+1] == '\'' {
+					f.i++
+					continue
+				}
+				f.i++
+				break
+			}
+		}
+		return parseYAMLScalar(f.s[start:f.i])
+	}
+
+	for f.i < len(f.s) && !strings.ContainsRune(",]}"+stops, rune(f.s[f.i])) {
+		f.i++
+	}
+	return parseYAMLScalar(strings.TrimSpace(f.s[start:f.i]))
+}
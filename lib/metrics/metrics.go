@@ -0,0 +1,362 @@
+// Package metrics is a tiny instrumentation lib for rod, cdp, and the launcher.
+// All the values of the [Default] registry are exposed via the Prometheus text format with the [Handler].
+// To also expose them via expvar, check the lib/metrics/vars package. This package doesn't import expvar,
+// because importing it registers "/debug/vars" on the http.DefaultServeMux.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets for latency histograms, in seconds.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// Registry of metrics.
+type Registry struct {
+	lock sync.Mutex
+	list []metric
+}
+
+type metric interface {
+	desc() *Desc
+	kind() string
+	write(w io.Writer)
+	snapshot() interface{}
+}
+
+// Desc of a metric.
+type Desc struct {
+	// Name of the metric, such as "rod_cdp_call_duration_seconds"
+	Name string
+
+	// Help text of the metric
+	Help string
+
+	// Label name of the metric, such as "method". If it's empty the metric has no label.
+	Label string
+}
+
+func (d *Desc) desc() *Desc { return d }
+
+func (d *Desc) series(label, suffix string, extra ...string) string {
+	pairs := []string{}
+	if d.Label != "" {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", d.Label, strconv.Quote(label)))
+	}
+	pairs = append(pairs, extra...)
+
+	if len(pairs) == 0 {
+		return d.Name + suffix
+	}
+	return d.Name + suffix + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// NewRegistry instance.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter creates a counter and registers it.
+func (r *Registry) Counter(name, help, label string) *Counter {
+	c := &Counter{Desc: Desc{name, help, label}, values: map[string]float64{}}
+	r.add(c)
+	return c
+}
+
+// Gauge creates a gauge and registers it.
+func (r *Registry) Gauge(name, help, label string) *Gauge {
+	g := &Gauge{Counter{Desc: Desc{name, help, label}, values: map[string]float64{}}}
+	r.add(g)
+	return g
+}
+
+// Histogram creates a histogram and registers it. If buckets is nil, [DefaultBuckets] will be used.
+func (r *Registry) Histogram(name, help, label string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{Desc: Desc{name, help, label}, buckets: buckets, values: map[string]*histogramValue{}}
+	r.add(h)
+	return h
+}
+
+func (r *Registry) add(m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.list = append(r.list, m)
+}
+
+func (r *Registry) metrics() []metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]metric{}, r.list...)
+}
+
+// WriteText writes all the metrics in Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) {
+	for _, m := range r.metrics() {
+		d := m.desc()
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.Name, d.Help, d.Name, m.kind())
+		m.write(w)
+	}
+}
+
+// Snapshot returns all the current values, the key is the metric name.
+func (r *Registry) Snapshot() map[string]interface{} {
+	s := map[string]interface{}{}
+	for _, m := range r.metrics() {
+		s[m.desc().Name] = m.snapshot()
+	}
+	return s
+}
+
+// Handler serves the metrics in Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// Counter is a metric that only goes up.
+type Counter struct {
+	Desc
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+// Add v to the value of the label.
+func (c *Counter) Add(label string, v float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[label] += v
+}
+
+// Inc the value of the label by 1.
+func (c *Counter) Inc(label string) {
+	c.Add(label, 1)
+}
+
+// Get the value of the label.
+func (c *Counter) Get(label string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.values[label]
+}
+
+func (c *Counter) kind() string { return "counter" }
+
+func (c *Counter) write(w io.Writer) {
+	snap := c.copy()
+	for _, label := range sortedKeys(snap) {
+		_, _ = fmt.Fprintf(w, "%s %s\n", c.series(label, ""), formatFloat(snap[label]))
+	}
+}
+
+func (c *Counter) snapshot() interface{} { return c.copy() }
+
+func (c *Counter) copy() map[string]float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s := make(map[string]float64, len(c.values))
+	for k, v := range c.values {
+		s[k] = v
+	}
+	return s
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	Counter
+}
+
+func (g *Gauge) kind() string { return "gauge" }
+
+// Set the value of the label.
+func (g *Gauge) Set(label string, v float64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.values[label] = v
+}
+
+// Dec the value of the label by 1.
+func (g *Gauge) Dec(label string) {
+	g.Add(label, -1)
+}
+
+// Histogram samples observations into buckets.
+type Histogram struct {
+	Desc
+
+	buckets []float64
+
+	lock   sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	Counts []uint64 `json:"counts"`
+	Count  uint64   `json:"count"`
+	Sum    float64  `json:"sum"`
+}
+
+// Observe a value for the label.
+func (h *Histogram) Observe(label string, v float64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	hv, has := h.values[label]
+	if !has {
+		hv = &histogramValue{Counts: make([]uint64, len(h.buckets))}
+		h.values[label] = hv
+	}
+
+	for i, b := range h.buckets {
+		if v <= b {
+			hv.Counts[i]++
+		}
+	}
+	hv.Count++
+	hv.Sum += v
+}
+
+// Since observes the seconds elapsed since the start for the label.
+func (h *Histogram) Since(label string, start time.Time) {
+	h.Observe(label, time.Since(start).Seconds())
+}
+
+func (h *Histogram) kind() string { return "histogram" }
+
+func (h *Histogram) write(w io.Writer) {
+	snap := h.copy()
+	for _, label := range sortedKeys(snap) {
+		hv := snap[label]
+		for i, b := range h.buckets {
+			_, _ = fmt.Fprintf(w, "%s %d\n", h.series(label, "_bucket", `le="`+formatFloat(b)+`"`), hv.Counts[i])
+		}
+		_, _ = fmt.Fprintf(w, "%s %d\n", h.series(label, "_bucket", `le="+Inf"`), hv.Count)
+		_, _ = fmt.Fprintf(w, "%s %s\n", h.series(label, "_sum"), formatFloat(hv.Sum))
+		_, _ = fmt.Fprintf(w, "%s %d\n", h.series(label, "_count"), hv.Count)
+	}
+}
+
+func (h *Histogram) snapshot() interface{} { return h.copy() }
+
+func (h *Histogram) copy() map[string]histogramValue {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s := make(map[string]histogramValue, len(h.values))
+	for k, v := range h.values {
+		cp := *v
+		cp.Counts = append([]uint64{}, v.Counts...)
+		s[k] = cp
+	}
+	return s
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Default registry for all the built-in metrics.
+var Default = NewRegistry()
+
+// Handler serves the [Default] registry in Prometheus text exposition format.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// The built-in metrics.
+var (
+	// CDPCallDuration of cdp.Client.Call, labeled by the cdp method.
+	CDPCallDuration = Default.Histogram("rod_cdp_call_duration_seconds",
+		"Latency of the cdp calls.", "method", nil)
+
+	// CDPCallErrors counts the errors of cdp.Client.Call, labeled by the code of cdp.Error.
+	// Errors that are not cdp.Error, such as network errors, are labeled as "other".
+	CDPCallErrors = Default.Counter("rod_cdp_call_errors_total",
+		"Number of failed cdp calls.", "code")
+
+	// CDPEvents counts the events received by cdp.Client, labeled by the cdp domain.
+	CDPEvents = Default.Counter("rod_cdp_events_total",
+		"Number of cdp events received.", "domain")
+
+	// PagesAlive is the number of rod.Page instances that are attached to targets.
+	PagesAlive = Default.Gauge("rod_pages_alive",
+		"Number of pages attached.", "")
+
+	// ElementsAlive is the number of rod.Element instances created but not released by rod.Element.Release.
+	ElementsAlive = Default.Gauge("rod_elements_alive",
+		"Number of elements not released.", "")
+
+	// PoolWait is the time spent waiting for rod.Pool.Get, labeled by the type of the pool.
+	PoolWait = Default.Histogram("rod_pool_wait_seconds",
+		"Time spent waiting for an item from the pool.", "type", nil)
+
+	// BrowserLaunches counts launcher.Launcher.Launch, labeled by the result, "ok" or "error".
+	BrowserLaunches = Default.Counter("rod_launcher_launches_total",
+		"Number of browser launches.", "result")
+
+	// BrowserLaunchDuration is the time spent by launcher.Launcher.Launch, including the download.
+	BrowserLaunchDuration = Default.Histogram("rod_launcher_launch_duration_seconds",
+		"Time spent to launch a browser.", "", nil)
+
+	// BrowsersAlive is the number of browser processes launched by the launcher that haven't exited.
+	BrowsersAlive = Default.Gauge("rod_launcher_browsers_alive",
+		"Number of running browser processes.", "")
+
+	// ManagerSessions is the number of active sessions of launcher.Manager.
+	ManagerSessions = Default.Gauge("rod_manager_sessions_alive",
+		"Number of active sessions of the manager.", "")
+
+	// ManagerSessionDuration is the lifetime of the sessions of launcher.Manager.
+	ManagerSessionDuration = Default.Histogram("rod_manager_session_duration_seconds",
+		"Lifetime of the sessions of the manager.", "",
+		[]float64{1, 10, 60, 300, 600, 1800, 3600, 7200, 21600, 86400})
+
+	// ManagerQueued is the number of launch requests waiting for a free slot of launcher.Manager.
+	ManagerQueued = Default.Gauge("rod_manager_sessions_queued",
+		"Number of launch requests waiting in the queue of the manager.", "")
+
+	// ManagerRejections counts the launch requests rejected by launcher.Manager, labeled by the reason,
+	// such as "unauthorized", "flags", "quota", or "queue_timeout".
+	ManagerRejections = Default.Counter("rod_manager_rejections_total",
+		"Number of launch requests rejected by the manager.", "reason")
+
+	// ManagerPool counts the sessions of launcher.Manager that used the pre-launched browsers, labeled by
+	// the result, "hit" or "miss".
+	ManagerPool = Default.Counter("rod_manager_pool_total",
+		"Number of sessions that tried the pre-launched browsers of the manager.", "result")
+
+	// ManagerKills counts the sessions killed by launcher.Manager, labeled by the reason, "idle" or "max_duration".
+	ManagerKills = Default.Counter("rod_manager_kills_total",
+		"Number of sessions killed by the manager.", "reason")
+
+	// ManagerReattaches counts the disconnected sessions of launcher.Manager that have a reattach grace period,
+	// labeled by the result, "ok" or "timeout".
+	ManagerReattaches = Default.Counter("rod_manager_reattaches_total",
+		"Number of disconnected sessions that waited for the client to reattach.", "result")
+)
@@ -0,0 +1,81 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/ysmood/got"
+)
+
+var setup = got.Setup(nil)
+
+func TestText(t *testing.T) {
+	g := setup(t)
+
+	r := metrics.NewRegistry()
+
+	c := r.Counter("test_total", "Test counter.", "code")
+	c.Inc("1")
+	c.Add("2", 2)
+	g.Eq(c.Get("2"), 2.0)
+
+	gauge := r.Gauge("test_alive", "Test gauge.", "")
+	gauge.Inc("")
+	gauge.Inc("")
+	gauge.Dec("")
+
+	h := r.Histogram("test_seconds", "Test histogram.", "method", []float64{0.1, 1})
+	h.Observe(`a"b`, 0.5)
+	h.Observe(`a"b`, 2)
+	h.Observe("c", 0.05)
+
+	buf := &strings.Builder{}
+	r.WriteText(buf)
+
+	g.Eq(buf.String(), `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{code="1"} 1
+test_total{code="2"} 2
+# HELP test_alive Test gauge.
+# TYPE test_alive gauge
+test_alive 1
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{method="a\"b",le="0.1"} 0
+test_seconds_bucket{method="a\"b",le="1"} 1
+test_seconds_bucket{method="a\"b",le="+Inf"} 2
+test_seconds_sum{method="a\"b"} 2.5
+test_seconds_count{method="a\"b"} 2
+test_seconds_bucket{method="c",le="0.1"} 1
+test_seconds_bucket{method="c",le="1"} 1
+test_seconds_bucket{method="c",le="+Inf"} 1
+test_seconds_sum{method="c"} 0.05
+test_seconds_count{method="c"} 1
+`)
+
+	h.Since("d", time.Now().Add(-time.Minute))
+	buf.Reset()
+	r.WriteText(buf)
+	g.Has(buf.String(), `test_seconds_bucket{method="d",le="1"} 0`)
+	g.Has(buf.String(), `test_seconds_count{method="d"} 1`)
+
+	gauge.Set("", 10)
+	g.Eq(r.Snapshot()["test_alive"], map[string]float64{"": 10})
+}
+
+func TestDefault(t *testing.T) {
+	g := setup(t)
+
+	metrics.PagesAlive.Inc("")
+	defer metrics.PagesAlive.Dec("")
+
+	res := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	g.Has(res.Header().Get("Content-Type"), "text/plain")
+	g.Has(res.Body.String(), "# TYPE rod_cdp_call_duration_seconds histogram")
+	g.Has(res.Body.String(), "\nrod_pages_alive ")
+	g.Eq(metrics.Default.Snapshot()["rod_pages_alive"], map[string]float64{"": 1})
+}
@@ -0,0 +1,22 @@
+// Package vars publishes the [metrics.Default] registry via expvar with the name "rod".
+// It's a separate package because importing expvar registers "/debug/vars" on the http.DefaultServeMux,
+// which exposes the cmdline and memstats of the process, so only import it when you want that.
+package vars
+
+import (
+	"expvar"
+	"sync"
+
+	"github.com/halicoming/rod/lib/metrics"
+)
+
+var once sync.Once
+
+// Publish the [metrics.Default] registry via expvar with the name "rod", it's safe to call it multiple times.
+func Publish() {
+	once.Do(func() {
+		expvar.Publish("rod", expvar.Func(func() interface{} {
+			return metrics.Default.Snapshot()
+		}))
+	})
+}
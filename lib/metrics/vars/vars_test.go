@@ -0,0 +1,27 @@
+package vars_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/halicoming/rod/lib/metrics"
+	"github.com/halicoming/rod/lib/metrics/vars"
+	"github.com/ysmood/got"
+)
+
+func TestPublish(t *testing.T) {
+	g := got.T(t)
+
+	g.Nil(expvar.Get("rod"))
+
+	vars.Publish()
+	vars.Publish()
+
+	metrics.PagesAlive.Inc("")
+	defer metrics.PagesAlive.Dec("")
+
+	var v map[string]interface{}
+	g.E(json.Unmarshal([]byte(expvar.Get("rod").String()), &v))
+	g.Eq(v["rod_pages_alive"], map[string]interface{}{"": 1.0})
+}
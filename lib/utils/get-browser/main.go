@@ -2,15 +2,77 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/halicoming/rod/lib/launcher"
 	"github.com/halicoming/rod/lib/utils"
 )
 
+var (
+	rev     = flag.Int("rev", launcher.RevisionDefault, "revision of the chromium")
+	version = flag.String("version", "", `Chrome for Testing version, such as "stable", "120", or "120.0.6099.109", default is the defaults.Version`)
+	shell   = flag.Bool("headless-shell", false, "use the chrome-headless-shell build of the version")
+	dir     = flag.String("dir", launcher.DefaultBrowserDir, "root dir of the downloaded browsers")
+)
+
 func main() {
-	p, err := launcher.NewBrowser().Get()
-	utils.E(err)
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), `Usage:
+
+  get-browser [flags]                  download the browser if it doesn't exist, then print its path
+  get-browser [flags] export [file]    export the browser as an offline bundle, default file is "{name}.tar.gz"
+  get-browser [flags] import <file>    install an offline bundle, then print the browser path
+
+Flags:`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	b := launcher.NewBrowser()
+	b.Revision = *rev
+	b.HeadlessShell = *shell
+	b.RootDir = *dir
+
+	// keep the defaults.Version unless the flag is set
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "version" {
+			b.Version = *version
+		}
+	})
+
+	switch flag.Arg(0) {
+	case "":
+		fmt.Println(b.MustGet())
+
+	case "export":
+		utils.E(b.Resolve())
+
+		out := flag.Arg(1)
+		if out == "" {
+			out = filepath.Base(b.Dir()) + ".tar.gz"
+		}
+
+		f, err := os.Create(out)
+		utils.E(err)
+		defer func() { utils.E(f.Close()) }()
+
+		utils.E(b.Export(f))
+		fmt.Println(out)
+
+	case "import":
+		f, err := os.Open(flag.Arg(1))
+		utils.E(err)
+		defer func() { _ = f.Close() }()
+
+		i, err := b.Import(f)
+		utils.E(err)
+		fmt.Println(i.Bin)
 
-	fmt.Println(p)
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
 }
@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
@@ -132,3 +135,57 @@ func SplicePngVertical(files []ImgWithBox, format proto.PageCaptureScreenshotFor
 
 	return bs, nil
 }
+
+// EncodeY4M encodes the frames as a YUV4MPEG2 video with the fps and the 4:2:0 chroma subsampling,
+// such as the fake camera of the Launcher.FakeMedia. All the frames should have the same size, the fps should be positive.
+func EncodeY4M(w io.Writer, fps int, frames ...image.Image) error {
+	if fps <= 0 {
+		return fmt.Errorf("invalid fps: %d", fps)
+	}
+	if len(frames) == 0 {
+		return errors.New("no frame to encode")
+	}
+
+	size := frames[0].Bounds().Size()
+	cw, ch := (size.X+1)/2, (size.Y+1)/2
+
+	bw := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(bw, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg\n", size.X, size.Y, fps)
+
+	y := make([]byte, size.X*size.Y)
+	cb := make([]byte, cw*ch)
+	cr := make([]byte, cw*ch)
+
+	for i, frame := range frames {
+		b := frame.Bounds()
+		if b.Size() != size {
+			return fmt.Errorf("frame %d size %v doesn't match the first frame size %v", i, b.Size(), size)
+		}
+
+		// the chroma of each 2x2 block is the average of its pixels
+		sums := make([][3]int, cw*ch)
+		for py := 0; py < size.Y; py++ {
+			for px := 0; px < size.X; px++ {
+				r, g, bl, _ := frame.At(b.Min.X+px, b.Min.Y+py).RGBA()
+				yy, u, v := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+				y[py*size.X+px] = yy
+
+				s := &sums[py/2*cw+px/2]
+				s[0] += int(u)
+				s[1] += int(v)
+				s[2]++
+			}
+		}
+		for j, s := range sums {
+			cb[j] = uint8(s[0] / s[2])
+			cr[j] = uint8(s[1] / s[2])
+		}
+
+		_, _ = bw.WriteString("FRAME\n")
+		_, _ = bw.Write(y)
+		_, _ = bw.Write(cb)
+		_, _ = bw.Write(cr)
+	}
+
+	return bw.Flush()
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"bytes"
 	"image"
+	"image/color"
+	"image/draw"
 	"testing"
 
 	"github.com/halicoming/rod/lib/proto"
@@ -205,3 +207,28 @@ func TestNewImgProcessor(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeY4M(t *testing.T) {
+	g := setup(t)
+
+	red := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{R: 0xff, A: 0xff}), image.Point{}, draw.Src)
+	white := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	draw.Draw(white, white.Bounds(), image.White, image.Point{}, draw.Src)
+
+	buf := bytes.NewBuffer(nil)
+	g.E(EncodeY4M(buf, 30, red, white))
+
+	y, cb, cr := color.RGBToYCbCr(0xff, 0, 0)
+	header := "YUV4MPEG2 W3 H2 F30:1 Ip A1:1 C420jpeg\n"
+	frame := func(y, cb, cr byte) string {
+		return "FRAME\n" + string(bytes.Repeat([]byte{y}, 6)) +
+			string(bytes.Repeat([]byte{cb}, 2)) + string(bytes.Repeat([]byte{cr}, 2))
+	}
+	g.Eq(buf.String(), header+frame(y, cb, cr)+frame(0xff, 0x80, 0x80))
+
+	g.Err(EncodeY4M(buf, 30))
+	g.Eq(EncodeY4M(buf, 0, red).Error(), "invalid fps: 0")
+	g.Eq(EncodeY4M(buf, -1, red).Error(), "invalid fps: -1")
+	g.Err(EncodeY4M(buf, 30, red, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+}
@@ -0,0 +1,50 @@
+package rod
+
+import (
+	"context"
+
+	"github.com/halicoming/rod/lib/cdp"
+)
+
+// CallFunc is the signature of [CDPClient].Call .
+type CallFunc func(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error)
+
+// Middleware intercepts the traffic between rod and the [CDPClient] without replacing the client.
+// It's useful for cross-cutting behaviors, such as metrics, rate limiting, per-method timeouts,
+// retries on [cdp.Error] codes, audit logging, or mutating requests. Both fields are optional.
+type Middleware struct {
+	// Call wraps the next CallFunc in the chain. The last one in the chain sends the request to the [CDPClient].
+	Call func(next CallFunc) CallFunc
+
+	// Event is called for each event from the browser before rod dispatches it.
+	// The event can be modified in place, return false to drop the event.
+	Event func(e *cdp.Event) bool
+}
+
+// Use appends the middlewares to the browser. The middleware added first will be the outermost one,
+// it will see the calls first and the events last.
+// It should be called before [Browser.Connect].
+func (b *Browser) Use(list ...Middleware) *Browser {
+	b.middlewares = append(b.middlewares[:len(b.middlewares):len(b.middlewares)], list...)
+	return b
+}
+
+func (b *Browser) callChain() CallFunc {
+	call := CallFunc(b.client.Call)
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		if m := b.middlewares[i]; m.Call != nil {
+			call = m.Call(call)
+		}
+	}
+	return call
+}
+
+// Returns false if any middleware drops the event.
+func (b *Browser) filterEvent(e *cdp.Event) bool {
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		if m := b.middlewares[i]; m.Event != nil && !m.Event(e) {
+			return false
+		}
+	}
+	return true
+}
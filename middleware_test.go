@@ -0,0 +1,64 @@
+package rod_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/halicoming/rod"
+	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/cdp/fake"
+	"github.com/halicoming/rod/lib/proto"
+	"github.com/ysmood/got"
+)
+
+func TestMiddleware(t *testing.T) {
+	g := got.T(t)
+
+	order := []string{}
+	record := func(name string) rod.Middleware {
+		return rod.Middleware{
+			Call: func(next rod.CallFunc) rod.CallFunc {
+				return func(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+					if method == "Browser.getVersion" {
+						order = append(order, name)
+					}
+					return next(ctx, sessionID, method, params)
+				}
+			},
+		}
+	}
+
+	f := fake.New()
+	b := rod.New().Client(f).Use(record("a"), record("b"), rod.Middleware{
+		Call: func(next rod.CallFunc) rod.CallFunc {
+			return func(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error) {
+				if method == "Target.closeTarget" {
+					return nil, &cdp.Error{Code: 1}
+				}
+				return next(ctx, sessionID, method, params)
+			}
+		},
+		Event: func(e *cdp.Event) bool {
+			return e.Method != "Page.loadEventFired"
+		},
+	}).MustConnect()
+
+	b.MustVersion()
+	g.Eq(order, []string{"a", "b"})
+
+	_, err := proto.TargetCloseTarget{}.Call(b)
+	g.Eq(err, &cdp.Error{Code: 1})
+
+	p := b.MustPage()
+
+	loaded := false
+	wait := p.EachEvent(func(*proto.PageLoadEventFired) {
+		loaded = true
+	}, func(*proto.PageFrameStoppedLoading) bool {
+		return true
+	})
+	f.Emit(string(p.SessionID), "Page.loadEventFired", nil)
+	f.Emit(string(p.SessionID), "Page.frameStoppedLoading", nil)
+	wait()
+	g.False(loaded)
+}
@@ -120,10 +120,12 @@ func (b *Browser) MustSetCookies(cookies ...*proto.NetworkCookie) *Browser {
 // It will read the file into bytes then remove the file.
 func (b *Browser) MustWaitDownload() func() []byte {
 	tmpDir := filepath.Join(os.TempDir(), "rod", "downloads")
-	wait := b.WaitDownload(tmpDir)
+	wait, err := b.WaitDownloadE(tmpDir)
+	b.e(err)
 
 	return func() []byte {
-		info := wait()
+		info, err := wait()
+		b.e(err)
 		path := filepath.Join(tmpDir, info.GUID)
 		defer func() { _ = os.Remove(path) }()
 		data, err := os.ReadFile(path)
@@ -132,6 +134,13 @@ func (b *Browser) MustWaitDownload() func() []byte {
 	}
 }
 
+// MustTether is similar to [Browser.Tether].
+func (b *Browser) MustTether(remotePort int, localAddr string) (stop func()) {
+	s, err := b.Tether(remotePort, localAddr)
+	b.e(err)
+	return func() { b.e(s()) }
+}
+
 // MustVersion is similar to [Browser.Version].
 func (b *Browser) MustVersion() *proto.BrowserGetVersionResult {
 	v, err := b.Version()
@@ -227,6 +236,12 @@ func (p *Page) MustSetBlockedURLs(urls ...string) *Page {
 	return p
 }
 
+// MustGrantUserMedia is similar to [Page.GrantUserMedia].
+func (p *Page) MustGrantUserMedia() *Page {
+	p.e(p.GrantUserMedia())
+	return p
+}
+
 // MustNavigate is similar to [Page.Navigate].
 func (p *Page) MustNavigate(url string) *Page {
 	p.e(p.Navigate(url))
@@ -362,6 +377,15 @@ func (p *Page) MustHandleFileDialog() func(...string) {
 	}
 }
 
+// MustHandleFileDialogFromReaders is similar to [Page.HandleFileDialogFromReaders].
+func (p *Page) MustHandleFileDialogFromReaders() func(...File) {
+	setFiles, err := p.HandleFileDialogFromReaders()
+	p.e(err)
+	return func(files ...File) {
+		p.e(setFiles(files))
+	}
+}
+
 // MustScreenshot is similar to [Page.Screenshot].
 // If the toFile is "", it Page.will save output to "tmp/screenshots" folder, time as the file name.
 func (p *Page) MustScreenshot(toFile ...string) []byte {
@@ -894,6 +918,12 @@ func (el *Element) MustContainsElement(target *Element) bool {
 	return contains
 }
 
+// MustSetFilesFromReaders is similar to [Element.SetFilesFromReaders].
+func (el *Element) MustSetFilesFromReaders(files ...File) *Element {
+	el.e(el.SetFilesFromReaders(files))
+	return el
+}
+
 // MustSetFiles is similar to [Element.SetFiles].
 func (el *Element) MustSetFiles(paths ...string) *Element {
 	el.e(el.SetFiles(paths))
@@ -1164,7 +1194,7 @@ func (el *Element) MustGetXPath(optimized bool) string {
 
 // MustGet an elem from the pool. Use the [Pool[T].Put] to make it reusable later.
 func (p Pool[T]) MustGet(create func() *T) *T {
-	elem := <-p
+	elem := p.wait()
 	if elem == nil {
 		elem = create()
 	}
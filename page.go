@@ -8,11 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/halicoming/rod/lib/cdp"
 	"github.com/halicoming/rod/lib/devices"
 	"github.com/halicoming/rod/lib/js"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/proto"
 	"github.com/halicoming/rod/lib/utils"
 	"github.com/ysmood/goob"
@@ -167,6 +169,19 @@ func (p *Page) SetBlockedURLs(urls []string) error {
 	return proto.NetworkSetBlockedURLs{Urls: urls}.Call(p)
 }
 
+// GrantUserMedia grants the camera and microphone permissions to all the origins in the browser context
+// of the page, so that the getUserMedia won't prompt. Use it with the [launcher.Launcher.FakeMedia] to test
+// the media features headlessly.
+func (p *Page) GrantUserMedia() error {
+	return proto.BrowserGrantPermissions{
+		Permissions: []proto.BrowserPermissionType{
+			proto.BrowserPermissionTypeVideoCapture,
+			proto.BrowserPermissionTypeAudioCapture,
+		},
+		BrowserContextID: p.browser.BrowserContextID,
+	}.Call(p.browser)
+}
+
 // Navigate to the url. If the url is empty, "about:blank" will be used.
 // It will return immediately after the server responds the http header.
 func (p *Page) Navigate(url string) error {
@@ -409,30 +424,71 @@ func (p *Page) HandleDialog() (
 
 // HandleFileDialog return a functions that waits for the next file chooser dialog pops up and returns the element
 // for the event.
+// If the client of the browser implements the [RemoteFiles], the files will be uploaded to the remote host first.
 func (p *Page) HandleFileDialog() (func([]string) error, error) {
-	err := proto.PageSetInterceptFileChooserDialog{Enabled: true}.Call(p)
+	wait, err := p.waitFileDialog()
 	if err != nil {
 		return nil, err
 	}
 
-	var e proto.PageFileChooserOpened
-	w := p.WaitEvent(&e)
-
 	return func(paths []string) error {
-		w()
+		e, err := wait()
+		if err != nil {
+			return err
+		}
 
-		err := proto.PageSetInterceptFileChooserDialog{Enabled: false}.Call(p)
+		list, err := uploadFiles(p.ctx, p.browser.client, utils.AbsolutePaths(paths))
 		if err != nil {
 			return err
 		}
 
 		return proto.DOMSetFileInputFiles{
-			Files:         utils.AbsolutePaths(paths),
+			Files:         list,
 			BackendNodeID: e.BackendNodeID,
 		}.Call(p)
 	}, nil
 }
 
+// HandleFileDialogFromReaders is similar to [Page.HandleFileDialog], but it sets the in-memory files,
+// check [Element.SetFilesFromReaders].
+func (p *Page) HandleFileDialogFromReaders() (func([]File) error, error) {
+	wait, err := p.waitFileDialog()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(files []File) error {
+		e, err := wait()
+		if err != nil {
+			return err
+		}
+
+		el, err := p.ElementFromNode(&proto.DOMNode{BackendNodeID: e.BackendNodeID})
+		if err != nil {
+			return err
+		}
+
+		return el.SetFilesFromReaders(files)
+	}, nil
+}
+
+// waitFileDialog intercepts the next file chooser dialog, the returned function waits for it.
+func (p *Page) waitFileDialog() (func() (*proto.PageFileChooserOpened, error), error) {
+	err := proto.PageSetInterceptFileChooserDialog{Enabled: true}.Call(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var e proto.PageFileChooserOpened
+	w := p.WaitEvent(&e)
+
+	return func() (*proto.PageFileChooserOpened, error) {
+		w()
+
+		return &e, proto.PageSetInterceptFileChooserDialog{Enabled: false}.Call(p)
+	}, nil
+}
+
 // Screenshot captures the screenshot of current page.
 func (p *Page) Screenshot(fullPage bool, req *proto.PageCaptureScreenshot) ([]byte, error) {
 	if req == nil {
@@ -944,12 +1000,15 @@ func (p *Page) ElementFromObject(obj *proto.RuntimeRemoteObject) (*Element, erro
 		p = &clone
 	}
 
+	metrics.ElementsAlive.Inc("")
+
 	return &Element{
-		e:       p.e,
-		ctx:     p.ctx,
-		sleeper: p.sleeper,
-		page:    p,
-		Object:  obj,
+		e:        p.e,
+		ctx:      p.ctx,
+		sleeper:  p.sleeper,
+		page:     p,
+		Object:   obj,
+		released: &atomic.Bool{},
 	}, nil
 }
 
@@ -1040,7 +1099,11 @@ func (p *Page) initEvents() {
 	p.event = goob.New(p.ctx)
 	event := p.browser.Context(p.ctx).Event()
 
+	metrics.PagesAlive.Inc("")
+
 	go func() {
+		defer metrics.PagesAlive.Dec("")
+
 		for msg := range event {
 			detached := proto.TargetDetachedFromTarget{}
 			destroyed := proto.TargetTargetDestroyed{}
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -93,6 +94,22 @@ func TestSetBlockedURLs(t *testing.T) {
 	page.MustNavigate("https://github.com")
 }
 
+func TestPageGrantUserMedia(t *testing.T) {
+	g := setup(t)
+
+	page := g.newPage(g.blank())
+	defer func() {
+		g.E(proto.BrowserResetPermissions{BrowserContextID: page.Browser().BrowserContextID}.Call(page.Browser()))
+	}()
+
+	query := `async name => (await navigator.permissions.query({ name })).state`
+	g.Eq(page.MustEval(query, "camera").Str(), "prompt")
+
+	page.MustGrantUserMedia()
+	g.Eq(page.MustEval(query, "camera").Str(), "granted")
+	g.Eq(page.MustEval(query, "microphone").Str(), "granted")
+}
+
 func TestSetExtraHeaders(t *testing.T) {
 	g := setup(t)
 
@@ -712,6 +729,30 @@ func TestPageHandleFileDialog(t *testing.T) {
 	}
 }
 
+func TestPageHandleFileDialogFromReaders(t *testing.T) {
+	g := setup(t)
+
+	p := g.page.MustNavigate(g.srcFile("fixtures/input.html"))
+	el := p.MustElement(`[type=file]`)
+
+	setFiles := p.MustHandleFileDialogFromReaders()
+	el.MustClick()
+	setFiles(rod.File{Name: "a.txt", Data: strings.NewReader("ok")})
+
+	g.Eq(el.MustEval("() => this.files[0].name").Str(), "a.txt")
+
+	{
+		g.mc.stubErr(1, proto.PageSetInterceptFileChooserDialog{})
+		g.Err(p.HandleFileDialogFromReaders())
+	}
+	{
+		g.mc.stubErr(1, proto.DOMResolveNode{})
+		setFiles, _ := p.HandleFileDialogFromReaders()
+		el.MustClick()
+		g.Err(setFiles(nil))
+	}
+}
+
 func TestPageScreenshot(t *testing.T) {
 	g := setup(t)
 
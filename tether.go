@@ -0,0 +1,119 @@
+package rod
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/halicoming/rod/lib/launcher"
+	"github.com/halicoming/rod/lib/proto"
+)
+
+// Tetherable is an optional interface of the [CDPClient] to open the side-channel of a tethering connection,
+// such as the [launcher.ManagedClient]. Check [Browser.Tether].
+type Tetherable interface {
+	// Tether opens the side-channel of the connection accepted by the browser, the connectionID is
+	// from the [proto.TetheringAccepted] event.
+	Tether(ctx context.Context, connectionID string) (io.ReadWriteCloser, error)
+}
+
+var _ Tetherable = &launcher.ManagedClient{}
+
+// TetherUnsupportedError error.
+type TetherUnsupportedError struct{}
+
+func (e *TetherUnsupportedError) Error() string {
+	return "the cdp client doesn't implement rod.Tetherable"
+}
+
+// Tether forwards the connections to the localhost:remotePort on the host of the browser to the localAddr
+// on the host of rod, so that the pages of a remote browser can reach the dev servers of the test machine.
+// Such as:
+//
+//	stop := browser.MustTether(3000, "127.0.0.1:3000")
+//	defer stop()
+//	page.MustNavigate("http://localhost:3000")
+//
+// It uses the [proto.TetheringBind], each connection accepted by the browser is piped via a side-channel
+// of the client, so the client must implement the [Tetherable], such as the [launcher.Launcher.ManagedClient].
+// Call the returned function to stop the forwarding.
+func (b *Browser) Tether(remotePort int, localAddr string) (stop func() error, err error) {
+	t, ok := b.client.(Tetherable)
+	if !ok {
+		return nil, &TetherUnsupportedError{}
+	}
+
+	ctx, cancel := context.WithCancel(b.ctx)
+	wg := &sync.WaitGroup{}
+
+	wait := b.Context(ctx).EachEvent(func(e *proto.TetheringAccepted) {
+		if e.Port != remotePort {
+			return
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := tetherConn(ctx, t, e.ConnectionID, localAddr)
+			if err != nil {
+				b.logger.Println("tether", remotePort, e.ConnectionID, err)
+			}
+		}()
+	})
+
+	err = proto.TetheringBind{Port: remotePort}.Call(b)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait()
+	}()
+
+	return func() error {
+		err := proto.TetheringUnbind{Port: remotePort}.Call(b)
+		cancel()
+		<-done
+		wg.Wait()
+		return err
+	}, nil
+}
+
+// tetherConn pipes the side-channel of the connection to the localAddr until one side closes.
+func tetherConn(ctx context.Context, t Tetherable, connectionID, localAddr string) error {
+	remote, err := t.Tether(ctx, connectionID)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = remote.Close() }()
+
+	local, err := (&net.Dialer{}).DialContext(ctx, "tcp", localAddr)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = local.Close() }()
+
+	// close both sides when the forwarding stops
+	stop := context.AfterFunc(ctx, func() {
+		_ = remote.Close()
+		_ = local.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = io.Copy(remote, local)
+		_ = remote.Close()
+	}()
+
+	_, _ = io.Copy(local, remote)
+	_ = local.Close()
+	<-done
+
+	return nil
+}
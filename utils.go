@@ -18,6 +18,8 @@ import (
 	"time"
 
 	"github.com/halicoming/rod/lib/cdp"
+	"github.com/halicoming/rod/lib/launcher"
+	"github.com/halicoming/rod/lib/metrics"
 	"github.com/halicoming/rod/lib/proto"
 	"github.com/halicoming/rod/lib/utils"
 )
@@ -28,6 +30,70 @@ type CDPClient interface {
 	Call(ctx context.Context, sessionID, method string, params interface{}) ([]byte, error)
 }
 
+// RemoteFiles is an optional interface of the [CDPClient] to transfer files with the host of a remote browser,
+// such as the [launcher.ManagedClient]. If the client implements it, [Element.SetFiles] uploads the local
+// files before it sets them, and [Browser.WaitDownload] pulls the downloaded file back to the local dir.
+type RemoteFiles interface {
+	// UploadFile to the remote host and returns the path of it on the remote host.
+	// The name is the slash separated path relative to the RemoteDir.
+	UploadFile(ctx context.Context, name string, r io.Reader) (string, error)
+
+	// DownloadFile from the remote host, the name is the slash separated path relative to the RemoteDir.
+	DownloadFile(ctx context.Context, name string, w io.Writer) error
+
+	// RemoteDir returns the path of the dir on the remote host to store the files.
+	RemoteDir(ctx context.Context) (string, error)
+}
+
+var _ RemoteFiles = &launcher.ManagedClient{}
+
+// downloadFile from the remote host to the local path.
+func downloadFile(ctx context.Context, rf RemoteFiles, name, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = rf.DownloadFile(ctx, name, f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// uploadFiles to the remote host if the client implements the [RemoteFiles], it returns the remote paths.
+func uploadFiles(ctx context.Context, client CDPClient, paths []string) ([]string, error) {
+	rf, ok := client.(RemoteFiles)
+	if !ok {
+		return paths, nil
+	}
+
+	// use a random dir to avoid the conflicts and keep the base names for the file inputs
+	dir := "uploads/" + utils.RandString(8)
+
+	list := []string{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+
+		remote, err := rf.UploadFile(ctx, dir+"/"+filepath.Base(p), f)
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, remote)
+	}
+	return list, nil
+}
+
 // Message represents a cdp.Event.
 type Message struct {
 	SessionID proto.TargetSessionID
@@ -104,13 +170,19 @@ func NewPool[T any](limit int) Pool[T] {
 
 // Get a elem from the pool, allow error. Use the [Pool[T].Put] to make it reusable later.
 func (p Pool[T]) Get(create func() (*T, error)) (elem *T, err error) {
-	elem = <-p
+	elem = p.wait()
 	if elem == nil {
 		elem, err = create()
 	}
 	return
 }
 
+// wait for an elem and observe the time spent.
+func (p Pool[T]) wait() *T {
+	defer metrics.PoolWait.Since(reflect.TypeOf((*T)(nil)).Elem().Name(), time.Now())
+	return <-p
+}
+
 // Put an elem back to the pool.
 func (p Pool[T]) Put(elem *T) {
 	p <- elem